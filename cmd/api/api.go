@@ -16,11 +16,14 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/tiskae/go-social/docs" // This is required to generate Swagger docs
 	"github.com/tiskae/go-social/internal/auth"
+	"github.com/tiskae/go-social/internal/blob"
 	"github.com/tiskae/go-social/internal/env"
 	"github.com/tiskae/go-social/internal/mailer"
+	"github.com/tiskae/go-social/internal/ranking"
 	"github.com/tiskae/go-social/internal/ratelimiter"
 	"github.com/tiskae/go-social/internal/store"
 	"github.com/tiskae/go-social/internal/store/cache"
+	"github.com/tiskae/go-social/internal/unfurl"
 	"go.uber.org/zap"
 
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -34,6 +37,9 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	blobStore     blob.BlobStore
+	unfurler      *unfurl.Unfurler
+	unfurlQueue   chan string
 }
 
 type config struct {
@@ -47,6 +53,52 @@ type config struct {
 	auth        authConfig
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
+	posts       postsConfig
+	media       mediaConfig
+	unfurl      unfurlConfig
+	timelines   timelinesConfig
+	feed        feedConfig
+}
+
+type postsConfig struct {
+	trashRetention    time.Duration
+	purgeInterval     time.Duration
+	schedulerInterval time.Duration
+}
+
+type mediaConfig struct {
+	// backend is where uploads are stored: local or s3
+	backend  string
+	localDir string
+	s3       blob.S3Config
+	// baseURL is the public URL blob keys are appended to
+	baseURL         string
+	maxUploadSize   int64
+	orphanRetention time.Duration
+	cleanupInterval time.Duration
+}
+
+type unfurlConfig struct {
+	workers          int
+	queueSize        int
+	fetchTimeout     time.Duration
+	maxPageSize      int64
+	backfillInterval time.Duration
+}
+
+type timelinesConfig struct {
+	// celebrityFollowers is the follower count from which an account's
+	// activity is merged into timelines on read rather than written to them
+	celebrityFollowers int
+	updateTimeout      time.Duration
+}
+
+type feedConfig struct {
+	// the ranked feed ranks the latest rankingCandidates posts of the
+	// rankingWindow before the time of ranking
+	rankingWindow     time.Duration
+	rankingCandidates int
+	rankingWeights    ranking.Weights
 }
 
 type authConfig struct {
@@ -126,30 +178,95 @@ func (app *application) mount() http.Handler {
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/", app.createPostHandler)
+			r.Get("/deleted", app.checkRole("moderator", app.getDeletedPostsHandler))
+			r.Get("/drafts", app.getDraftsHandler)
+			r.Post("/{postID}/restore", app.restorePostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleware) // excluded for /comments route internally
 				r.Get("/", app.getPostByIDHandler)
 				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
 				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+				r.Post("/publish", app.publishPostHandler)
+				r.Put("/repost", app.repostHandler)
+				r.Delete("/repost", app.undoRepostHandler)
+				r.Put("/bookmark", app.bookmarkPostHandler)
+				r.Delete("/bookmark", app.deleteBookmarkHandler)
+				r.Post("/poll/votes", app.votePollHandler)
+				r.Get("/thread", app.getPostThreadHandler)
+				r.Put("/pin", app.pinPostHandler)
+				r.Delete("/pin", app.unpinPostHandler)
+				r.Put("/content-warning", app.checkRole("moderator", app.forceContentWarningHandler))
+				r.Delete("/content-warning", app.checkRole("moderator", app.removeContentWarningHandler))
 
 				r.Route("/comments", func(r chi.Router) {
 					r.Get("/", app.getCommentsByPostIDHandler)
 					r.Post("/", app.createPostCommentHandler)
+
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentsContextMiddleware)
+						r.Get("/replies", app.getCommentRepliesHandler)
+						r.Put("/reaction", app.reactToCommentHandler)
+						r.Delete("/reaction", app.deleteCommentReactionHandler)
+						r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+						r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+					})
 				})
 			})
 		})
 
+		r.Route("/media", func(r chi.Router) {
+			r.With(app.AuthTokenMiddleware).Post("/", app.uploadMediaHandler)
+			// files are public so they can be embedded, their keys are unguessable
+			r.Get("/files/*", app.getMediaFileHandler)
+		})
+
+		r.Route("/explore", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getExploreHandler)
+			r.Get("/popular", app.getPopularPostsHandler)
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/followed", app.getFollowedTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+			r.Put("/{tag}/follow", app.followTagHandler)
+			r.Delete("/{tag}/follow", app.unfollowTagHandler)
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
 
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Get("/mentions", app.getMyMentionsHandler)
+				r.Put("/pins", app.reorderPinsHandler)
+				r.Get("/preferences", app.getPreferencesHandler)
+				r.Patch("/preferences", app.updatePreferencesHandler)
+
+				r.Route("/bookmarks", func(r chi.Router) {
+					r.Get("/", app.getBookmarksHandler)
+					r.Get("/folders", app.getBookmarkFoldersHandler)
+					r.Post("/folders", app.createBookmarkFolderHandler)
+					r.Delete("/folders/{folderID}", app.deleteBookmarkFolderHandler)
+				})
+			})
+
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				// r.Use(app.userContextMiddleware)
 
 				r.Get("/", app.getUserByIDHandler)
+				r.Get("/posts", app.getUserPostsHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
@@ -184,6 +301,12 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	// Background jobs stop once the server starts shutting down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startJobs(jobsCtx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -195,6 +318,8 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal caught", "signal", s.String())
 
+		stopJobs()
+
 		shutdown <- srv.Shutdown(ctx)
 	}()
 
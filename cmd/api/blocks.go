@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// targetUserID reads the ID of the user acted on from the URL, refusing the
+// current user's own.
+func targetUserID(r *http.Request, self int64) (int64, error) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return 0, errors.New("user id must be a valid integer")
+	}
+
+	if userID == self {
+		return 0, errors.New("user id must not be your own")
+	}
+
+	return userID, nil
+}
+
+// BlockUser godoc
+//
+//	@Summary		Block a user
+//	@Description	Block a user, so that neither of the two users sees the posts of the other or can follow the other. The follows between them are removed.
+//	@Tags			users
+//	@Produce		json
+//	@Param			user_id	path		int		true	"User ID"
+//	@Success		204		{nil}		nil		"User blocked"
+//	@Failure		400		{string}	error	"Invalid user ID"
+//	@Failure		404		{string}	error	"User not found"
+//	@Failure		409		{string}	error	"User already blocked"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{user_id}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	blockedID, err := targetUserID(r, user.ID)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Blocks.Block(r.Context(), user.ID, blockedID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// blocking removed the follows both ways
+	app.unfollowTimeline(user.ID, blockedID)
+	app.unfollowTimeline(blockedID, user.ID)
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblock a user
+//	@Description	Unblock a user. Follows removed by the block are not restored.
+//	@Tags			users
+//	@Produce		json
+//	@Param			user_id	path		int		true	"User ID"
+//	@Success		204		{nil}		nil		"User unblocked"
+//	@Failure		400		{string}	error	"Invalid user ID"
+//	@Failure		404		{string}	error	"User not blocked"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{user_id}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	blockedID, err := targetUserID(r, user.ID)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Blocks.Unblock(r.Context(), user.ID, blockedID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+type fakeBlocks struct {
+	err     error
+	blocked [][2]int64
+}
+
+func (f *fakeBlocks) Block(_ context.Context, userID int64, blockedID int64) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.blocked = append(f.blocked, [2]int64{userID, blockedID})
+	return nil
+}
+
+func (f *fakeBlocks) Unblock(_ context.Context, userID int64, blockedID int64) error {
+	return f.err
+}
+
+type fakeFollowers struct {
+	err error
+}
+
+func (f *fakeFollowers) Follow(context.Context, int64, int64) error   { return f.err }
+func (f *fakeFollowers) Unfollow(context.Context, int64, int64) error { return f.err }
+
+func TestBlockUserHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		storeErr error
+		want     int
+	}{
+		{"blocked", "2", nil, http.StatusNoContent},
+		{"invalid id", "abc", nil, http.StatusBadRequest},
+		{"own id", "1", nil, http.StatusBadRequest},
+		{"already blocked", "2", store.ErrConflict, http.StatusConflict},
+		{"unknown user", "2", store.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := &fakeBlocks{err: tt.storeErr}
+			app := newTestApplication(t, store.Storage{Blocks: blocks})
+
+			w := httptest.NewRecorder()
+			r := newTestRequest(http.MethodPut, "/v1/users/"+tt.userID+"/block", &store.User{ID: 1}, map[string]string{"userID": tt.userID})
+
+			app.blockUserHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+
+			if tt.want == http.StatusNoContent && (len(blocks.blocked) != 1 || blocks.blocked[0] != [2]int64{1, 2}) {
+				t.Errorf("blocked %v, want [[1 2]]", blocks.blocked)
+			}
+		})
+	}
+}
+
+func TestUnblockUserHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		storeErr error
+		want     int
+	}{
+		{"unblocked", "2", nil, http.StatusNoContent},
+		{"own id", "1", nil, http.StatusBadRequest},
+		{"not blocked", "2", store.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, store.Storage{Blocks: &fakeBlocks{err: tt.storeErr}})
+
+			w := httptest.NewRecorder()
+			r := newTestRequest(http.MethodDelete, "/v1/users/"+tt.userID+"/block", &store.User{ID: 1}, map[string]string{"userID": tt.userID})
+
+			app.unblockUserHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowBlockedUser(t *testing.T) {
+	app := newTestApplication(t, store.Storage{Followers: &fakeFollowers{err: store.ErrBlocked}})
+
+	w := httptest.NewRecorder()
+	r := newTestRequest(http.MethodPut, "/v1/users/2/follow", &store.User{ID: 1}, map[string]string{"userID": "2"})
+
+	app.followUserHandler(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
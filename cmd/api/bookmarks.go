@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+type BookmarkPostPayload struct {
+	FolderID *int64 `json:"folder_id" validate:"omitempty,gte=1"`
+}
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmark a post
+//	@Description	Save a post for later, optionally in one of the user's bookmark folders
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			folder_id	body		int		false	"Bookmark folder ID"
+//	@Success		204			{nil}		nil		"Post bookmarked"
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		404			{string}	error	"Post or folder not found"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Router			/posts/{id}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload BookmarkPostPayload
+
+	// the body is optional
+	if err := readJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	bookmark := store.Bookmark{
+		UserID:   user.ID,
+		PostID:   post.ID,
+		FolderID: payload.FolderID,
+	}
+
+	if err := app.store.Bookmarks.Create(r.Context(), &bookmark); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// DeleteBookmark godoc
+//
+//	@Summary		Remove a bookmark
+//	@Description	Remove a post from the bookmarks of the user with the auth token
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{nil}		nil		"Bookmark removed"
+//	@Failure		404	{string}	error	"Bookmark not found"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/posts/{id}/bookmark [delete]
+func (app *application) deleteBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Bookmarks.Delete(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		List my bookmarks
+//	@Description	List the bookmarks of the user with the auth token, newest first
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			limit		query		int		false	"How many bookmarks to return"
+//	@Param			cursor		query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Param			folder_id	query		int		false	"Only list bookmarks in this folder"
+//	@Success		200			{object}	[]store.Bookmark
+//	@Failure		400			{string}	error	"Invalid query params"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	bq := store.PaginatedBookmarksQuery{
+		Limit: 20,
+	}
+
+	bq, err := bq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(bq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	bookmarks, next, err := app.store.Bookmarks.GetByUserID(r.Context(), user.ID, bq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, bookmarks, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+type CreateBookmarkFolderPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// CreateBookmarkFolder godoc
+//
+//	@Summary		Create a bookmark folder
+//	@Description	Create a bookmark folder for the user with the auth token
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	body		string	true	"Folder name"	maxlength(100)
+//	@Success		201		{object}	store.BookmarkFolder
+//	@Failure		400		{string}	error	"Invalid body"
+//	@Failure		409		{string}	error	"Folder already exists"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Router			/users/me/bookmarks/folders [post]
+func (app *application) createBookmarkFolderHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateBookmarkFolderPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	folder := store.BookmarkFolder{
+		UserID: user.ID,
+		Name:   payload.Name,
+	}
+
+	if err := app.store.Bookmarks.CreateFolder(r.Context(), &folder); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, folder); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetBookmarkFolders godoc
+//
+//	@Summary		List my bookmark folders
+//	@Description	List the bookmark folders of the user with the auth token
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookmarkFolder
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/users/me/bookmarks/folders [get]
+func (app *application) getBookmarkFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	folders, err := app.store.Bookmarks.GetFolders(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, folders); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// DeleteBookmarkFolder godoc
+//
+//	@Summary		Delete a bookmark folder
+//	@Description	Delete a bookmark folder, keeping its bookmarks outside of any folder
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			folder_id	path		int		true	"Folder ID"
+//	@Success		204			{nil}		nil		"Folder deleted"
+//	@Failure		400			{string}	error	"Invalid folder ID"
+//	@Failure		404			{string}	error	"Folder not found"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Router			/users/me/bookmarks/folders/{folder_id} [delete]
+func (app *application) deleteBookmarkFolderHandler(w http.ResponseWriter, r *http.Request) {
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "folderID"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("folder id must be a valid integer"))
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Bookmarks.DeleteFolder(r.Context(), user.ID, folderID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -9,22 +10,40 @@ import (
 	"github.com/tiskae/go-social/internal/store"
 )
 
+type CommentKey string
+
+const commentKey CommentKey = "comment"
+
 type CreateCommentPayload struct {
-	UserID  int64  `json:"user_id" validate:"required"`
-	Content string `json:"content" validate:"required"`
+	Content         string `json:"content" validate:"required"`
+	ParentCommentID *int64 `json:"parent_comment_id" validate:"omitempty,gte=1"`
+}
+
+var errParentCommentNotFound = errors.New("parent comment not found")
+
+// defaultCommentsQuery lists comments oldest first, with their first replies
+// three levels down.
+func defaultCommentsQuery() store.CommentsQuery {
+	return store.CommentsQuery{
+		Limit:        20,
+		Sort:         store.CommentSortOldest,
+		Depth:        3,
+		RepliesLimit: 3,
+	}
 }
 
 // CreateComment godoc
 //
 //	@Summary		Create a comment
-//	@Description	Create a new comment
+//	@Description	Create a new comment as the user with the auth token
 //	@Tags			comments
 //	@Produce		json
 //	@Param			post_id	path		int		true	"Post ID"
-//	@Param			user_id	body		int		true	"User ID"
 //	@Param			content	body		string	true	"Comment content"
+//	@Param			parent_comment_id	body	int	false	"ID of the comment being replied to"
 //	@Success		201		{object}	store.Comment
 //	@Failure		400		{string}	error	"Invalid body"
+//	@Failure		403		{string}	error	"Comments restricted by the post's comment policy"
 //	@Failure		500		{string}	error	"Internal server error"
 //	@Router			/posts/{post_id}/comments [post]
 func (app *application) createPostCommentHandler(w http.ResponseWriter, r *http.Request) {
@@ -51,20 +70,76 @@ func (app *application) createPostCommentHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	// moderators can comment whatever the post's comment policy
+	allowed, err := app.store.Posts.CanComment(ctx, getPostFromCtx(r), user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !allowed {
+		allowed, err = app.checkRolePrecedence(ctx, user, "moderator")
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !allowed {
+		app.forbiddenErrorResponse(w, r, errors.New("comments on this post are restricted"))
+		return
+	}
+
 	comment := store.Comment{
-		PostID:  postID,
-		UserID:  payload.UserID,
-		Content: payload.Content,
+		PostID:          postID,
+		UserID:          user.ID,
+		Content:         payload.Content,
+		User:            store.User{ID: user.ID, Username: user.Username},
+		ParentCommentID: payload.ParentCommentID,
 	}
 
-	err = app.store.Comments.Create(r.Context(), &comment)
+	// replies go to live comments of the same post
+	if comment.ParentCommentID != nil {
+		parent, err := app.store.Comments.GetByID(ctx, postID, *comment.ParentCommentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestErrorResponse(w, r, errParentCommentNotFound)
+			default:
+				app.internalServerErrorResponse(w, r, err)
+			}
+			return
+		}
 
-	// handling failed comment creation on DB
+		if parent.Deleted {
+			app.badRequestErrorResponse(w, r, errParentCommentNotFound)
+			return
+		}
+	}
+
+	comment.Mentions, err = app.resolveMentions(ctx, comment.Content)
 	if err != nil {
 		app.internalServerErrorResponse(w, r, err)
 		return
 	}
 
+	err = app.store.Comments.Create(ctx, &comment)
+
+	// handling failed comment creation on DB
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// the parent comment got deleted meanwhile
+			app.badRequestErrorResponse(w, r, errParentCommentNotFound)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
 		app.internalServerErrorResponse(w, r, err)
 	}
@@ -74,12 +149,17 @@ func (app *application) createPostCommentHandler(w http.ResponseWriter, r *http.
 // GetPostComments godoc
 //
 //	@Summary		Get post comments
-//	@Description	Create a new comment
+//	@Description	List the top-level comments of a post, each with a tree of its replies. Comments with more replies than listed (see replies_count) have them loaded from their replies endpoint. Deleted comments with replies are listed as tombstones.
 //	@Tags			comments
 //	@Produce		json
-//	@Param			post_id	path		int	true	"Post ID"
+//	@Param			post_id			path		int		true	"Post ID"
+//	@Param			limit			query		int		false	"How many comments to return, 1 to 50 (default 20)"
+//	@Param			cursor			query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Param			sort			query		string	false	"Order of the comments: oldest (default), newest or most_reacted"
+//	@Param			depth			query		int		false	"Levels of replies to include, 1 to 10 (default 3)"
+//	@Param			replies_limit	query		int		false	"Replies listed per comment, 1 to 20 (default 3)"
 //	@Success		200		{object}	[]store.Comment
-//	@Failure		400		{string}	error	"Invalid post ID"
+//	@Failure		400		{string}	error	"Invalid query"
 //	@Failure		404		{string}	error	"Post not found"
 //	@Failure		500		{string}	error	"Internal server error"
 //	@Router			/posts/id/comments [get]
@@ -91,7 +171,18 @@ func (app *application) getCommentsByPostIDHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), postID)
+	cq, err := defaultCommentsQuery().Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	comments, next, err := app.store.Comments.GetByPostID(r.Context(), postID, cq)
 
 	if err != nil {
 		switch {
@@ -104,7 +195,278 @@ func (app *application) getCommentsByPostIDHandler(w http.ResponseWriter, r *htt
 		}
 	}
 
-	if err = app.jsonResponse(w, http.StatusOK, comments); err != nil {
+	if err = app.paginatedJSONResponse(w, http.StatusOK, comments, next); err != nil {
 		app.internalServerErrorResponse(w, r, err)
 	}
 }
+
+// GetCommentReplies godoc
+//
+//	@Summary		Get comment replies
+//	@Description	List the replies to a comment, oldest first, each with a tree of its own replies
+//	@Tags			comments
+//	@Produce		json
+//	@Param			post_id			path		int		true	"Post ID"
+//	@Param			comment_id		path		int		true	"Comment ID"
+//	@Param			limit			query		int		false	"How many replies to return, 1 to 50 (default 20)"
+//	@Param			cursor			query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Param			depth			query		int		false	"Levels of replies to include, 1 to 10 (default 3)"
+//	@Param			replies_limit	query		int		false	"Replies listed per reply, 1 to 20 (default 3)"
+//	@Success		200				{object}	[]store.Comment
+//	@Failure		400				{string}	error	"Invalid query"
+//	@Failure		404				{string}	error	"Comment not found"
+//	@Failure		500				{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{post_id}/comments/{comment_id}/replies [get]
+func (app *application) getCommentRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	cq, err := defaultCommentsQuery().Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	comment := getCommentFromCtx(r)
+
+	replies, next, err := app.store.Comments.GetReplies(r.Context(), comment.PostID, comment.ID, cq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, replies, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+type CommentReactionPayload struct {
+	Emoji string `json:"emoji" validate:"required,max=32"`
+}
+
+// ReactToComment godoc
+//
+//	@Summary		React to a comment
+//	@Description	React to a comment with an emoji, replacing the user's previous reaction to it
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			post_id		path		int		true	"Post ID"
+//	@Param			comment_id	path		int		true	"Comment ID"
+//	@Param			emoji		body		string	true	"Reaction emoji"
+//	@Success		204			{nil}		nil		"Reaction saved"
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		404			{string}	error	"Comment not found or deleted"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{post_id}/comments/{comment_id}/reaction [put]
+func (app *application) reactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CommentReactionPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	comment := getCommentFromCtx(r)
+	user := getUserFromContext(r)
+
+	// tombstones are listed to keep their replies in place, but can't be
+	// reacted to
+	if comment.Deleted {
+		app.notFoundErrorResponse(w, r, errors.New("comment was deleted"))
+		return
+	}
+
+	if err := app.store.CommentReactions.Set(r.Context(), comment.ID, user.ID, payload.Emoji); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// DeleteCommentReaction godoc
+//
+//	@Summary		Remove a comment reaction
+//	@Description	Remove the user's reaction to a comment
+//	@Tags			comments
+//	@Produce		json
+//	@Param			post_id		path		int		true	"Post ID"
+//	@Param			comment_id	path		int		true	"Comment ID"
+//	@Success		204			{nil}		nil		"Reaction removed"
+//	@Failure		404			{string}	error	"Reaction not found"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{post_id}/comments/{comment_id}/reaction [delete]
+func (app *application) deleteCommentReactionHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.CommentReactions.Delete(r.Context(), comment.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Update a comment
+//	@Description	Edit the content of a comment, marking it as edited
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			post_id		path		int		true	"Post ID"
+//	@Param			comment_id	path		int		true	"Comment ID"
+//	@Param			content		body		string	true	"Comment content"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		403			{string}	error	"Forbidden"
+//	@Failure		404			{string}	error	"Comment not found"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{post_id}/comments/{comment_id} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if comment.Deleted {
+		app.notFoundErrorResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	var payload UpdateCommentPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	comment.Content = payload.Content
+
+	var err error
+
+	comment.Mentions, err = app.resolveMentions(ctx, comment.Content)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Delete a comment
+//	@Description	Delete a comment along with its mentions. Comments with replies are kept as tombstones, without content nor author.
+//	@Tags			comments
+//	@Produce		json
+//	@Param			post_id		path		int		true	"Post ID"
+//	@Param			comment_id	path		int		true	"Comment ID"
+//	@Success		204			{nil}		nil		"Comment deleted"
+//	@Failure		403			{string}	error	"Forbidden"
+//	@Failure		404			{string}	error	"Comment not found"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{post_id}/comments/{comment_id} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if comment.Deleted {
+		app.notFoundErrorResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// commentsContextMiddleware loads the comment of the post in the request
+// context. Comments of another post are reported as not found.
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestErrorResponse(w, r, errors.New("comment id is required as a valid integer"))
+			return
+		}
+
+		post := getPostFromCtx(r)
+
+		comment, err := app.store.Comments.GetByID(r.Context(), post.ID, commentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundErrorResponse(w, r, err)
+			default:
+				app.internalServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		newCtx := context.WithValue(r.Context(), commentKey, comment)
+
+		next.ServeHTTP(w, r.WithContext(newCtx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentKey).(*store.Comment)
+	return comment
+}
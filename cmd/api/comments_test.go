@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+type fakeCommentReactions struct {
+	set int
+}
+
+func (f *fakeCommentReactions) Set(context.Context, int64, int64, string) error {
+	f.set++
+	return nil
+}
+
+func (f *fakeCommentReactions) Delete(context.Context, int64, int64) error { return nil }
+
+func TestReactToCommentHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		deleted bool
+		want    int
+	}{
+		{"reacted", false, http.StatusNoContent},
+		{"deleted comment", true, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reactions := &fakeCommentReactions{}
+			app := newTestApplication(t, store.Storage{CommentReactions: reactions})
+
+			comment := &store.Comment{ID: 3, PostID: 5, Deleted: tt.deleted}
+
+			w := httptest.NewRecorder()
+			r := newTestRequest(http.MethodPut, "/v1/posts/5/comments/3/reaction", &store.User{ID: 1}, nil)
+			r.Body = io.NopCloser(strings.NewReader(`{"emoji": "👍"}`))
+			r = r.WithContext(context.WithValue(r.Context(), commentKey, comment))
+
+			app.reactToCommentHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+
+			if tt.deleted && reactions.set != 0 {
+				t.Error("reacted to a deleted comment")
+			}
+		})
+	}
+}
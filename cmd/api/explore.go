@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// popularPostsLimit is how many popular posts are computed, and so can be
+// paged through, for a time window.
+const popularPostsLimit = 100
+
+// GetExplore godoc
+//
+//	@Summary		Explore public posts
+//	@Description	List the public posts of all users, newest first, leaving out users blocked or muted by the viewer and users who blocked them
+//	@Tags			explore
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size, 1 to 20 (default 20)"
+//	@Param			cursor	query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Param			tags	query		string	false	"Comma-separated tags the posts must all carry"
+//	@Param			search	query		string	false	"Text to look for in titles and contents"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{string}	error	"Invalid query params"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/explore [get]
+func (app *application) getExploreHandler(w http.ResponseWriter, r *http.Request) {
+	eq := store.ExploreQuery{
+		Limit: 20,
+		Tags:  []string{},
+	}
+
+	eq, err := eq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(eq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, next, err := app.store.Posts.GetPublic(r.Context(), user.ID, eq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, posts, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetPopularPosts godoc
+//
+//	@Summary		List popular posts
+//	@Description	List the public posts published over the last hours that got the most engagement (comments, reactions to them and reposts), most engaging first, leaving out users blocked or muted by the viewer and users who blocked them. The ranking is refreshed every minute, and the pages of a cursor all come from the ranking of its first page.
+//	@Tags			explore
+//	@Produce		json
+//	@Param			hours	query		int		false	"Time window in hours, 1 to 168 (default 24)"
+//	@Param			limit	query		int		false	"Page size, 1 to 20 (default 20)"
+//	@Param			cursor	query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{string}	error	"Invalid query params"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/explore/popular [get]
+func (app *application) getPopularPostsHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PopularQuery{
+		Limit: 20,
+		Hours: 24,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	ids, rankedAt, err := app.getPopularPosts(ctx, pq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	page, next := popularPage(ids, pq, rankedAt)
+
+	posts, err := app.store.Posts.GetPublicByIDs(ctx, page, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, posts, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// popularPage returns the IDs of the page of popular posts the query asks
+// for, along with the cursor of the next page (nil on the last page). The
+// cursors hold the position of their page and the time the ranking was
+// computed at, so that later pages come from the same ranking.
+func popularPage(ids []int64, pq store.PopularQuery, rankedAt time.Time) ([]int64, *store.Cursor) {
+	position := 0
+	if pq.Cursor != nil {
+		position = min(max(int(pq.Cursor.Count), 0), len(ids))
+	}
+
+	end := min(position+pq.Limit, len(ids))
+
+	var next *store.Cursor
+	if end < len(ids) {
+		next = positionCursor(rankedAt, end)
+	}
+
+	return ids[position:end], next
+}
+
+// positionCursor returns the cursor of the page starting at position in a
+// ranking computed at rankedAt.
+func positionCursor(rankedAt time.Time, position int) *store.Cursor {
+	return &store.Cursor{CreatedAt: rankedAt, Count: int64(position)}
+}
+
+// getPopularPosts returns the IDs of the most popular posts over the last
+// hours of the query, along with the time they were ranked at. First pages
+// get the current ranking and later pages the one of their cursor. Rankings
+// are cached when Redis is enabled, and computed again as of their time when
+// they expired or Redis fails.
+func (app *application) getPopularPosts(ctx context.Context, pq store.PopularQuery) ([]int64, time.Time, error) {
+	// engagement is stored to the second, so the ranking takes the last
+	// whole second, which no later engagement can fall in
+	rankedAt := time.Now().Truncate(time.Second).Add(-time.Second)
+	if pq.Cursor != nil {
+		rankedAt = pq.Cursor.CreatedAt
+	}
+
+	compute := func(rankedAt time.Time) ([]int64, time.Time, error) {
+		since := rankedAt.Add(-time.Duration(pq.Hours) * time.Hour)
+
+		ids, err := app.store.Posts.GetPopular(ctx, rankedAt, since, app.config.feed.rankingWeights, popularPostsLimit)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		return ids, rankedAt, nil
+	}
+
+	if !app.config.redisCfg.enabled {
+		return compute(rankedAt)
+	}
+
+	if pq.Cursor == nil {
+		current, err := app.cacheStorage.Explore.GetPopularRankedAt(ctx, pq.Hours)
+		if err != nil {
+			app.logger.Warnw("failed to read popular posts, falling back to the database", "hours", pq.Hours, "error", err.Error())
+			return compute(rankedAt)
+		}
+
+		if current != nil {
+			rankedAt = *current
+		}
+	}
+
+	ids, err := app.cacheStorage.Explore.GetPopular(ctx, pq.Hours, rankedAt)
+	if err != nil {
+		app.logger.Warnw("failed to read popular posts, falling back to the database", "hours", pq.Hours, "error", err.Error())
+		return compute(rankedAt)
+	}
+
+	if ids != nil {
+		return ids, rankedAt, nil
+	}
+
+	ids, rankedAt, err = compute(rankedAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if err := app.cacheStorage.Explore.SetPopular(ctx, pq.Hours, rankedAt, ids); err != nil {
+		app.logger.Warnw("failed to cache popular posts", "hours", pq.Hours, "error", err.Error())
+		return ids, rankedAt, nil
+	}
+
+	if pq.Cursor == nil {
+		if err := app.cacheStorage.Explore.SetPopularRankedAt(ctx, pq.Hours, rankedAt); err != nil {
+			app.logger.Warnw("failed to cache popular posts", "hours", pq.Hours, "error", err.Error())
+		}
+	}
+
+	return ids, rankedAt, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tiskae/go-social/internal/store"
+	"github.com/tiskae/go-social/internal/store/cache"
+)
+
+// fakeExploreCache holds rankings of popular posts by the Unix time they were
+// computed at, for a single time window.
+type fakeExploreCache struct {
+	err      error
+	current  *time.Time
+	rankings map[int64][]int64
+}
+
+func (f *fakeExploreCache) GetPopularRankedAt(_ context.Context, _ int) (*time.Time, error) {
+	return f.current, f.err
+}
+
+func (f *fakeExploreCache) SetPopularRankedAt(_ context.Context, _ int, rankedAt time.Time) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.current = &rankedAt
+	return nil
+}
+
+func (f *fakeExploreCache) GetPopular(_ context.Context, _ int, rankedAt time.Time) ([]int64, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.rankings[rankedAt.Unix()], nil
+}
+
+func (f *fakeExploreCache) SetPopular(_ context.Context, _ int, rankedAt time.Time, ids []int64) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.rankings[rankedAt.Unix()] = ids
+	return nil
+}
+
+func TestPositionCursorRoundTrip(t *testing.T) {
+	rankedAt := time.Date(2026, 3, 1, 12, 30, 15, 123456789, time.UTC)
+
+	for _, position := range []int{0, 1, 20, 99} {
+		cursor, err := store.DecodeCursor(positionCursor(rankedAt, position).Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor() of position %d error = %v", position, err)
+		}
+
+		if cursor.Count != int64(position) || !cursor.CreatedAt.Equal(rankedAt) {
+			t.Errorf("DecodeCursor() = %+v, want position %d at %v", cursor, position, rankedAt)
+		}
+	}
+}
+
+func TestPopularPagePaging(t *testing.T) {
+	ids := make([]int64, 45)
+	for i := range ids {
+		ids[i] = int64(100 + i)
+	}
+
+	var (
+		got    []int64
+		pages  int
+		cursor string
+	)
+
+	for {
+		target := "/v1/explore/popular?limit=20"
+		if cursor != "" {
+			target += "&cursor=" + url.QueryEscape(cursor)
+		}
+
+		pq, err := store.PopularQuery{Limit: 20, Hours: 24}.Parse(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("Parse() of page %d error = %v", pages+1, err)
+		}
+
+		page, next := popularPage(ids, pq, time.Now())
+		got = append(got, page...)
+		pages++
+
+		if next == nil {
+			break
+		}
+
+		if pages > len(ids) {
+			t.Fatal("paging doesn't end")
+		}
+
+		cursor = next.Encode()
+	}
+
+	if pages != 3 || !slices.Equal(got, ids) {
+		t.Errorf("paged through %v in %d pages, want %v in 3", got, pages, ids)
+	}
+}
+
+func TestPopularPageOutOfRange(t *testing.T) {
+	ids := []int64{1, 2, 3}
+
+	for _, position := range []int64{-5, 3, 50} {
+		pq := store.PopularQuery{Limit: 20, Cursor: &store.Cursor{CreatedAt: time.Now(), Count: position}}
+
+		page, next := popularPage(ids, pq, time.Now())
+
+		want := []int64{}
+		if position < 0 {
+			want = ids
+		}
+
+		if !slices.Equal(page, want) || next != nil {
+			t.Errorf("popularPage() at position %d = %v, %v, want %v, nil", position, page, next, want)
+		}
+	}
+}
+
+func TestGetPopularPosts(t *testing.T) {
+	rankedAt := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	current := rankedAt.Add(5 * time.Minute)
+	later := store.PopularQuery{Limit: 20, Hours: 24, Cursor: positionCursor(rankedAt, 20)}
+	first := store.PopularQuery{Limit: 20, Hours: 24}
+
+	tests := []struct {
+		name string
+		pq   store.PopularQuery
+		// cache is nil when Redis is disabled
+		cache *fakeExploreCache
+		// computedAt is the time the ranking is computed again at in SQL,
+		// nil when it comes from the cache and any time for zero
+		computedAt *time.Time
+		want       []int64
+		wantAt     *time.Time
+		// wantCurrent is the time of the current ranking left in the cache,
+		// the one returned if nil
+		wantCurrent *time.Time
+	}{
+		{
+			name:       "later page without Redis",
+			pq:         later,
+			computedAt: &rankedAt,
+			want:       []int64{9, 8},
+			wantAt:     &rankedAt,
+		},
+		{
+			name:        "later page from the cache",
+			pq:          later,
+			cache:       &fakeExploreCache{current: &current, rankings: map[int64][]int64{rankedAt.Unix(): {5, 4}, current.Unix(): {1}}},
+			want:        []int64{5, 4},
+			wantAt:      &rankedAt,
+			wantCurrent: &current,
+		},
+		{
+			name:        "later page of an expired ranking",
+			pq:          later,
+			cache:       &fakeExploreCache{current: &current, rankings: map[int64][]int64{current.Unix(): {1}}},
+			computedAt:  &rankedAt,
+			want:        []int64{9, 8},
+			wantAt:      &rankedAt,
+			wantCurrent: &current,
+		},
+		{
+			name:       "later page when Redis fails",
+			pq:         later,
+			cache:      &fakeExploreCache{err: errors.New("redis down"), rankings: map[int64][]int64{}},
+			computedAt: &rankedAt,
+			want:       []int64{9, 8},
+			wantAt:     &rankedAt,
+		},
+		{
+			name:        "first page from the current ranking",
+			pq:          first,
+			cache:       &fakeExploreCache{current: &current, rankings: map[int64][]int64{current.Unix(): {1}}},
+			want:        []int64{1},
+			wantAt:      &current,
+			wantCurrent: &current,
+		},
+		{
+			name:       "first page without a current ranking",
+			pq:         first,
+			cache:      &fakeExploreCache{rankings: map[int64][]int64{}},
+			computedAt: &time.Time{},
+			want:       []int64{9, 8},
+		},
+		{
+			name:       "first page when Redis fails",
+			pq:         first,
+			cache:      &fakeExploreCache{err: errors.New("redis down"), rankings: map[int64][]int64{}},
+			computedAt: &time.Time{},
+			want:       []int64{9, 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if tt.computedAt != nil {
+				var at, since any = sqlmock.AnyArg(), sqlmock.AnyArg()
+				if !tt.computedAt.IsZero() {
+					at, since = *tt.computedAt, tt.computedAt.Add(-24*time.Hour)
+				}
+
+				mock.ExpectQuery(`SELECT id`).
+					WithArgs(since, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), popularPostsLimit, at).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9).AddRow(8))
+			}
+
+			app := newTestApplication(t, store.NewStorage(db))
+			if tt.cache != nil {
+				app.config.redisCfg.enabled = true
+				app.cacheStorage = cache.Storage{Explore: tt.cache}
+			}
+
+			ids, at, err := app.getPopularPosts(context.Background(), tt.pq)
+			if err != nil {
+				t.Fatalf("getPopularPosts() error = %v", err)
+			}
+
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("getPopularPosts() = %v, want %v", ids, tt.want)
+			}
+
+			if tt.wantAt != nil && !at.Equal(*tt.wantAt) {
+				t.Errorf("getPopularPosts() ranked at %v, want %v", at, *tt.wantAt)
+			}
+
+			if tt.cache != nil && tt.cache.err == nil {
+				if !slices.Equal(tt.cache.rankings[at.Unix()], tt.want) {
+					t.Errorf("cached ranking = %v, want %v", tt.cache.rankings[at.Unix()], tt.want)
+				}
+
+				wantCurrent := tt.wantCurrent
+				if wantCurrent == nil {
+					wantCurrent = &at
+				}
+
+				if tt.cache.current == nil || !tt.cache.current.Equal(*wantCurrent) {
+					t.Errorf("current ranking at %v, want %v", tt.cache.current, *wantCurrent)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -1,23 +1,31 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/tiskae/go-social/internal/ranking"
 	"github.com/tiskae/go-social/internal/store"
 )
 
 // GetUserFeed godoc
 //
 //	@Summary		Get user feed
-//	@Description	Get the feed for the user with the auth token
+//	@Description	Get the feed for the user with the auth token: posts and reposts of followed users and posts carrying followed tags. Pages are linked through next_cursor and prev_cursor, also given in the Link header; offset is still supported for older clients. A page can hold fewer posts than the limit when some were deleted or hidden since they reached the feed.
 //	@Tags			users
 //	@Produce		json
 //	@Param			limit	query		int			false	"How many posts to return"
-//	@Param			offset	query		int			false	"Offset to start from"
-//	@Param			limit	query		string		false	"Whether to sort in ascending (asc) or descending(desc, default)"
+//	@Param			cursor	query		string		false	"Cursor of the page to fetch, from next_cursor or prev_cursor"
+//	@Param			offset	query		int			false	"Offset to start from, instead of a cursor"
+//	@Param			sort	query		string		false	"Whether to sort in ascending (asc) or descending(desc, default) order of time, or to rank the latest posts For you (ranked)"
 //	@Param			tags	query		[]string	false	"Tags to filter by"
+//	@Param			search	query		string		false	"Text to look for in titles and contents"
+//	@Param			since	query		string		false	"Only posts from this time on: RFC 3339, 2006-01-02 15:04:05 (UTC) or a duration ago such as 24h or 7d"
+//	@Param			until	query		string		false	"Only posts up to this time, in the same formats as since"
 //	@Success		200		{object}	[]store.PostWithMetadata
-//	@Failure		400		{string}	error	"Invalid query params"
+//	@Header			200		{string}	Link	"Links to the next and previous pages"
+//	@Failure		400		{string}	error	"Invalid query params, or a cursor made for another sort"
 //	@Failure		500		{string}	error	"Internal server error"
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
@@ -46,14 +54,81 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 
 	ctx := r.Context()
 
-	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
+	var (
+		feed       []store.PostWithMetadata
+		next, prev *store.Cursor
+	)
+
+	if fq.Sort == "ranked" {
+		feed, next, prev, err = app.getRankedFeed(ctx, user.ID, fq)
+	} else {
+		feed, next, prev, err = app.getHomeTimeline(ctx, user.ID, fq)
+	}
 
 	if err != nil {
 		app.internalServerErrorResponse(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := app.cursorPageJSONResponse(w, r, http.StatusOK, feed, next, prev); err != nil {
 		app.internalServerErrorResponse(w, r, err)
 	}
 }
+
+// getRankedFeed returns a page of the user's "For you" feed: the latest posts
+// of their home feed ordered by score. Its cursors hold the time the feed was
+// ranked at along with the position of the page, and the candidates are
+// scored from their activity and engagement as of that time, so that all
+// pages come from the same ranking.
+func (app *application) getRankedFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, *store.Cursor, *store.Cursor, error) {
+	// activity is stored to the second, so the ranking takes the last whole
+	// second, which no later activity can fall in
+	rankedAt := time.Now().Truncate(time.Second).Add(-time.Second)
+	position := fq.Offset
+
+	if fq.Cursor != nil {
+		rankedAt = fq.Cursor.CreatedAt
+		position = max(int(fq.Cursor.Count), 0)
+	}
+
+	since, until := rankedAt.Add(-app.config.feed.rankingWindow), rankedAt
+
+	if fq.Since != nil && fq.Since.After(since) {
+		since = *fq.Since
+	}
+
+	if fq.Until != nil && fq.Until.Before(until) {
+		until = *fq.Until
+	}
+
+	candidates, err := app.store.Posts.GetRankingCandidates(ctx, userID, fq, rankedAt, since, until, app.config.feed.rankingCandidates)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ranked := ranking.Rank(candidates, app.config.feed.rankingWeights, rankedAt)
+
+	var next, prev *store.Cursor
+
+	if end := position + fq.Limit; end < len(ranked) {
+		next = &store.Cursor{Sort: fq.Sort, CreatedAt: rankedAt, Count: int64(end)}
+	}
+
+	if position > 0 {
+		prev = &store.Cursor{Sort: fq.Sort, CreatedAt: rankedAt, Count: int64(max(position-fq.Limit, 0))}
+	}
+
+	page := ranked[min(position, len(ranked)):min(position+fq.Limit, len(ranked))]
+
+	entries := make([]store.TimelineEntry, len(page))
+	for i, c := range page {
+		entries[i] = store.TimelineEntry{PostID: c.PostID, ActivityAt: c.ActivityAt}
+	}
+
+	feed, err := app.store.Posts.GetFeedPosts(ctx, userID, entries)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return feed, next, prev, nil
+}
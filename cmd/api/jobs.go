@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// contentHTMLBatchSize is how many old posts get rendered per transaction
+const contentHTMLBatchSize = 500
+
+func (app *application) startJobs(ctx context.Context) {
+	go func() {
+		if err := app.backfillContentHTML(ctx); err != nil {
+			app.logger.Errorw("background job failed", "job", "render content of old posts", "error", err.Error())
+		}
+	}()
+	go app.runPeriodically(ctx, "purge deleted posts", app.config.posts.purgeInterval, app.purgeDeletedPosts)
+	go app.runPeriodically(ctx, "publish scheduled posts", app.config.posts.schedulerInterval, app.publishScheduledPosts)
+	go app.runPeriodically(ctx, "purge orphan media", app.config.media.cleanupInterval, app.purgeOrphanMedia)
+	go app.runPeriodically(ctx, "unfurl pending links", app.config.unfurl.backfillInterval, app.unfurlPendingLinks)
+
+	for range app.config.unfurl.workers {
+		go app.runUnfurlWorker(ctx)
+	}
+}
+
+// runPeriodically calls job every interval until ctx is cancelled.
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+			}
+		}
+	}
+}
+
+func (app *application) purgeDeletedPosts(ctx context.Context) error {
+	cutoff := time.Now().Add(-app.config.posts.trashRetention)
+
+	purged, err := app.store.Posts.PurgeDeleted(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged deleted posts", "count", purged)
+	}
+
+	return nil
+}
+
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+	published, err := app.store.Posts.PublishScheduled(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i := range published {
+		app.fanOutPost(&published[i])
+	}
+
+	if len(published) > 0 {
+		app.logger.Infow("published scheduled posts", "count", len(published))
+	}
+
+	return nil
+}
+
+// backfillContentHTML renders the HTML of the posts written before content
+// was rendered on write, batch after batch until none is left.
+func (app *application) backfillContentHTML(ctx context.Context) error {
+	for {
+		rendered, err := app.store.Posts.RenderMissingHTML(ctx, contentHTMLBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if rendered == 0 {
+			return nil
+		}
+
+		app.logger.Infow("rendered content of old posts", "count", rendered)
+	}
+}
+
+// orphanMediaBatchSize bounds how many orphan media one run of the cleanup
+// job deletes.
+const orphanMediaBatchSize = 100
+
+// purgeOrphanMedia deletes media that were never attached to a post, or
+// whose post got purged, along with their files.
+func (app *application) purgeOrphanMedia(ctx context.Context) error {
+	cutoff := time.Now().Add(-app.config.media.orphanRetention)
+
+	orphans, err := app.store.Media.GetOrphans(ctx, cutoff, orphanMediaBatchSize)
+	if err != nil {
+		return err
+	}
+
+	purged := 0
+
+	for _, m := range orphans {
+		err := app.store.Media.DeleteOrphan(ctx, m.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			// attached to a post since it was listed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		purged++
+		app.deleteMediaFiles(ctx, m)
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged orphan media", "count", purged)
+	}
+
+	return nil
+}
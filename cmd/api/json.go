@@ -3,8 +3,11 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/tiskae/go-social/internal/store"
 )
 
 var (
@@ -47,3 +50,61 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+// paginatedJSONResponse writes data along with the cursor of the next page,
+// omitted on the last page.
+func (app *application) paginatedJSONResponse(w http.ResponseWriter, status int, data any, next *store.Cursor) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+
+	env := envelope{Data: data}
+	if next != nil {
+		env.NextCursor = next.Encode()
+	}
+
+	return writeJSON(w, status, &env)
+}
+
+// cursorPageJSONResponse writes data along with the cursors of the next and
+// previous pages, omitted when there is no such page, and links to those
+// pages in a Link header (RFC 5988).
+func (app *application) cursorPageJSONResponse(w http.ResponseWriter, r *http.Request, status int, data any, next *store.Cursor, prev *store.Cursor) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+
+	env := envelope{Data: data}
+	links := []string{}
+
+	if next != nil {
+		env.NextCursor = next.Encode()
+		links = append(links, `<`+pageURL(r, env.NextCursor)+`>; rel="next"`)
+	}
+
+	if prev != nil {
+		env.PrevCursor = prev.Encode()
+		links = append(links, `<`+pageURL(r, env.PrevCursor)+`>; rel="prev"`)
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	return writeJSON(w, status, &env)
+}
+
+// pageURL returns the URL of the request with its cursor replaced, and its
+// offset dropped.
+func pageURL(r *http.Request, cursor string) string {
+	qs := r.URL.Query()
+	qs.Del("offset")
+	qs.Set("cursor", cursor)
+
+	u := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+
+	return u.String()
+}
@@ -7,12 +7,15 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/tiskae/go-social/internal/auth"
+	"github.com/tiskae/go-social/internal/blob"
 	"github.com/tiskae/go-social/internal/db"
 	"github.com/tiskae/go-social/internal/env"
 	"github.com/tiskae/go-social/internal/mailer"
+	"github.com/tiskae/go-social/internal/ranking"
 	"github.com/tiskae/go-social/internal/ratelimiter"
 	"github.com/tiskae/go-social/internal/store"
 	"github.com/tiskae/go-social/internal/store/cache"
+	"github.com/tiskae/go-social/internal/unfurl"
 	"go.uber.org/zap"
 )
 
@@ -91,6 +94,50 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		posts: postsConfig{
+			trashRetention:    time.Hour * 24 * 30, // 30 days
+			purgeInterval:     time.Hour,
+			schedulerInterval: time.Minute,
+		},
+		media: mediaConfig{
+			backend:  env.GetString("MEDIA_BACKEND", "local"),
+			localDir: env.GetString("MEDIA_LOCAL_DIR", "./uploads"),
+			s3: blob.S3Config{
+				Endpoint:  env.GetString("S3_ENDPOINT", "http://localhost:9000"),
+				Region:    env.GetString("S3_REGION", "us-east-1"),
+				Bucket:    env.GetString("S3_BUCKET", "gophersocial"),
+				AccessKey: env.GetString("S3_ACCESS_KEY", ""),
+				SecretKey: env.GetString("S3_SECRET_KEY", ""),
+			},
+			baseURL:         env.GetString("MEDIA_BASE_URL", "http://localhost:8080/v1/media/files"),
+			maxUploadSize:   int64(env.GetInt("MEDIA_MAX_UPLOAD_SIZE", 8<<20)), // 8 MB
+			orphanRetention: time.Hour * 24,
+			cleanupInterval: time.Hour,
+		},
+		unfurl: unfurlConfig{
+			workers:          env.GetInt("UNFURL_WORKERS", 2),
+			queueSize:        100,
+			fetchTimeout:     time.Second * 5,
+			maxPageSize:      1 << 20, // 1 MB
+			backfillInterval: time.Minute,
+		},
+		timelines: timelinesConfig{
+			celebrityFollowers: env.GetInt("TIMELINE_CELEBRITY_FOLLOWERS", 10000),
+			updateTimeout:      time.Second * 30,
+		},
+		feed: feedConfig{
+			rankingWindow:     time.Hour * 72,
+			rankingCandidates: env.GetInt("FEED_RANKING_CANDIDATES", 500),
+			rankingWeights: ranking.Weights{
+				HalfLife:       time.Duration(env.GetFloat("FEED_RANKING_HALF_LIFE_HOURS", 12) * float64(time.Hour)),
+				Recency:        env.GetFloat("FEED_RANKING_RECENCY_WEIGHT", 1),
+				Comments:       env.GetFloat("FEED_RANKING_COMMENTS_WEIGHT", 1),
+				Reactions:      env.GetFloat("FEED_RANKING_REACTIONS_WEIGHT", 0.5),
+				Reposts:        env.GetFloat("FEED_RANKING_REPOSTS_WEIGHT", 2),
+				AuthorAffinity: env.GetFloat("FEED_RANKING_AUTHOR_AFFINITY_WEIGHT", 1),
+				TagAffinity:    env.GetFloat("FEED_RANKING_TAG_AFFINITY_WEIGHT", 0.5),
+			},
+		},
 	}
 
 	// Database
@@ -119,6 +166,23 @@ func main() {
 		cfg.rateLimiter.TimeFrame,
 	)
 
+	// Blob storage
+	var blobStore blob.BlobStore
+
+	switch cfg.media.backend {
+	case "s3":
+		blobStore, err = blob.NewS3Store(cfg.media.s3)
+	default:
+		blobStore, err = blob.NewLocalStore(cfg.media.localDir)
+	}
+
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// Link previews
+	unfurler := unfurl.New(cfg.unfurl.fetchTimeout, cfg.unfurl.maxPageSize)
+
 	// Mailer
 	mailer := mailer.NewSendgrid(cfg.mail.sendgrid.apiKey, cfg.mail.fromEmail)
 
@@ -132,6 +196,9 @@ func main() {
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
 		rateLimiter:   ratelimiter,
+		blobStore:     blobStore,
+		unfurler:      unfurler,
+		unfurlQueue:   make(chan string, cfg.unfurl.queueSize),
 	}
 
 	// Metrics collected
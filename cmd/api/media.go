@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tiskae/go-social/internal/blob"
+	"github.com/tiskae/go-social/internal/media"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+const maxMediaDescriptionLength = 1500
+
+// UploadMedia godoc
+//
+//	@Summary		Upload media
+//	@Description	Upload a JPEG, PNG or GIF image to attach to a post. The type is detected from the file content, metadata such as EXIF is stripped and a thumbnail is generated. Media not attached to a post within a day are deleted.
+//	@Tags			media
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file		formData	file		true	"Image file"
+//	@Param			description	formData	string		false	"Alt text"	maxlength(1500)
+//	@Param			sensitive	formData	bool		false	"Whether the media is hidden behind a click"
+//	@Success		201			{object}	store.Media
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		413			{string}	error	"File too large"
+//	@Failure		415			{string}	error	"Unsupported media type"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	maxSize := app.config.media.maxUploadSize
+
+	// leaves room for the multipart envelope and the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesErr):
+			app.payloadTooLargeResponse(w, r, fmt.Errorf("file must not exceed %d bytes", maxSize))
+		default:
+			app.badRequestErrorResponse(w, r, fmt.Errorf("file is required: %w", err))
+		}
+		return
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if int64(len(data)) > maxSize {
+		app.payloadTooLargeResponse(w, r, fmt.Errorf("file must not exceed %d bytes", maxSize))
+		return
+	}
+
+	description := strings.TrimSpace(r.FormValue("description"))
+	if utf8.RuneCountInString(description) > maxMediaDescriptionLength {
+		app.badRequestErrorResponse(w, r, fmt.Errorf("description must not exceed %d characters", maxMediaDescriptionLength))
+		return
+	}
+
+	sensitive := false
+	if value := r.FormValue("sensitive"); value != "" {
+		sensitive, err = strconv.ParseBool(value)
+		if err != nil {
+			app.badRequestErrorResponse(w, r, errors.New("sensitive must be a boolean"))
+			return
+		}
+	}
+
+	img, err := media.ProcessImage(data)
+	if err != nil {
+		switch err {
+		case media.ErrUnsupportedType:
+			app.unsupportedMediaTypeResponse(w, r, err)
+		case media.ErrTooManyPixels, media.ErrTooManyFrames:
+			app.badRequestErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	name := uuid.New().String()
+	upload := store.Media{
+		UserID:       user.ID,
+		BlobKey:      "media/" + name + img.Ext,
+		ThumbnailKey: "media/" + name + "_thumb" + img.ThumbnailExt,
+		ContentType:  img.ContentType,
+		Size:         int64(len(img.Data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		Description:  description,
+		Sensitive:    sensitive,
+	}
+	upload.URL = app.config.media.baseURL + "/" + upload.BlobKey
+	upload.ThumbnailURL = app.config.media.baseURL + "/" + upload.ThumbnailKey
+
+	if err := app.blobStore.Put(ctx, upload.BlobKey, img.ContentType, img.Data); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.blobStore.Put(ctx, upload.ThumbnailKey, img.ThumbnailContentType, img.Thumbnail); err != nil {
+		app.deleteMediaFiles(ctx, upload)
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Media.Create(ctx, &upload); err != nil {
+		app.deleteMediaFiles(ctx, upload)
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, upload); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetMediaFile godoc
+//
+//	@Summary		Fetch a media file
+//	@Description	Serve an uploaded media file or its thumbnail
+//	@Tags			media
+//	@Produce		image/jpeg,image/png,image/gif
+//	@Param			key	path		string	true	"Media file key"
+//	@Success		200	{file}		file
+//	@Failure		404	{string}	error	"Media not found"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/media/files/{key} [get]
+func (app *application) getMediaFileHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	file, contentType, err := app.blobStore.Get(r.Context(), key)
+	if err != nil {
+		switch err {
+		case blob.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	// a key never gets new content, uploads always get a new one
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, file); err != nil {
+		app.logger.Warnw("failed to serve media file", "key", key, "error", err.Error())
+	}
+}
+
+// deleteMediaFiles removes the files of an upload, logging failures since
+// there is nothing left to roll back.
+func (app *application) deleteMediaFiles(ctx context.Context, upload store.Media) {
+	for _, key := range []string{upload.BlobKey, upload.ThumbnailKey} {
+		if err := app.blobStore.Delete(ctx, key); err != nil {
+			app.logger.Errorw("failed to delete media file", "key", key, "error", err.Error())
+		}
+	}
+}
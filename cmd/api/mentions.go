@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/tiskae/go-social/internal/content"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// resolveMentions resolves the users mentioned in text through their
+// usernames, skipping the ones that don't belong to an active user.
+func (app *application) resolveMentions(ctx context.Context, text string) ([]store.Mention, error) {
+	userIDs := map[string]int64{}
+
+	for _, username := range content.MentionedUsernames(text) {
+		user, err := app.store.Users.GetByUsername(ctx, username)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		userIDs[username] = user.ID
+	}
+
+	return store.BuildMentions(text, userIDs), nil
+}
+
+// GetMyMentions godoc
+//
+//	@Summary		List my mentions
+//	@Description	List the posts and comments mentioning the user with the auth token, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"How many mentions to return"
+//	@Param			cursor	query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Success		200		{object}	[]store.MentionNotification
+//	@Failure		400		{string}	error	"Invalid query params"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Router			/users/me/mentions [get]
+func (app *application) getMyMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	cq := store.PaginatedCursorQuery{
+		Limit: 20,
+	}
+
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	mentions, next, err := app.store.Mentions.GetByUserID(r.Context(), user.ID, cq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, mentions, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
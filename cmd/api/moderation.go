@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+type ForceContentWarningPayload struct {
+	ContentWarning string `json:"content_warning" validate:"required,max=200"`
+	Sensitive      bool   `json:"sensitive"`
+	Reason         string `json:"reason" validate:"required,max=500"`
+}
+
+// ForceContentWarning godoc
+//
+//	@Summary		Force a content warning on a post
+//	@Description	Put a content warning on someone else's post (moderators only). The author can't change it afterwards, and the action is recorded for audit.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int		true	"Post ID"
+//	@Param			content_warning	body		string	true	"Warning shown in place of the content"	maxlength(200)
+//	@Param			sensitive		body		bool	false	"Whether to also mark the post's media as sensitive"
+//	@Param			reason			body		string	true	"Why the content warning was forced"	maxlength(500)
+//	@Success		200				{object}	store.Post
+//	@Failure		400				{string}	error	"Invalid body"
+//	@Failure		403				{string}	error	"Forbidden"
+//	@Failure		404				{string}	error	"Post not found"
+//	@Failure		500				{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/content-warning [put]
+func (app *application) forceContentWarningHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForceContentWarningPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	payload.ContentWarning = strings.TrimSpace(payload.ContentWarning)
+	payload.Reason = strings.TrimSpace(payload.Reason)
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	app.setContentWarning(w, r, payload.Sensitive, &store.ModerationAction{
+		Action:   store.ModerationForceContentWarning,
+		Reason:   payload.Reason,
+		NewValue: payload.ContentWarning,
+	})
+}
+
+type RemoveContentWarningPayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// RemoveContentWarning godoc
+//
+//	@Summary		Remove a content warning from a post
+//	@Description	Remove the content warning of a post, forced or not (moderators only). The action is recorded for audit.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			reason	body		string	true	"Why the content warning was removed"	maxlength(500)
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{string}	error	"Invalid body"
+//	@Failure		403		{string}	error	"Forbidden"
+//	@Failure		404		{string}	error	"Post not found"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/content-warning [delete]
+func (app *application) removeContentWarningHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RemoveContentWarningPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	payload.Reason = strings.TrimSpace(payload.Reason)
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	app.setContentWarning(w, r, false, &store.ModerationAction{
+		Action: store.ModerationRemoveContentWarning,
+		Reason: payload.Reason,
+	})
+}
+
+// setContentWarning applies a moderator's content warning action to the post
+// in the request context and responds with the updated post.
+func (app *application) setContentWarning(w http.ResponseWriter, r *http.Request, sensitive bool, action *store.ModerationAction) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	action.ModeratorID = user.ID
+
+	if err := app.store.Moderation.SetContentWarning(ctx, post, sensitive, action); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.logger.Infow("content warning moderated",
+		"action", action.Action, "post_id", post.ID, "moderator_id", user.ID, "audit_id", action.ID)
+
+	updated, err := app.store.Posts.GetByID(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, updated); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
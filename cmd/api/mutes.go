@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// MuteUser godoc
+//
+//	@Summary		Mute a user
+//	@Description	Mute a user, leaving their posts and reposts out of the home feed and tag feeds. Their posts can still be opened.
+//	@Tags			users
+//	@Produce		json
+//	@Param			user_id	path		int		true	"User ID"
+//	@Success		204		{nil}		nil		"User muted"
+//	@Failure		400		{string}	error	"Invalid user ID"
+//	@Failure		404		{string}	error	"User not found"
+//	@Failure		409		{string}	error	"User already muted"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{user_id}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	mutedID, err := targetUserID(r, user.ID)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Mutes.Mute(r.Context(), user.ID, mutedID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmute a user
+//	@Description	Unmute a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			user_id	path		int		true	"User ID"
+//	@Success		204		{nil}		nil		"User unmuted"
+//	@Failure		400		{string}	error	"Invalid user ID"
+//	@Failure		404		{string}	error	"User not muted"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{user_id}/mute [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	mutedID, err := targetUserID(r, user.ID)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Mutes.Unmute(r.Context(), user.ID, mutedID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+type fakeMutes struct {
+	err   error
+	muted [][2]int64
+}
+
+func (f *fakeMutes) Mute(_ context.Context, userID int64, mutedID int64) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.muted = append(f.muted, [2]int64{userID, mutedID})
+	return nil
+}
+
+func (f *fakeMutes) Unmute(_ context.Context, userID int64, mutedID int64) error {
+	return f.err
+}
+
+func TestMuteUserHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		storeErr error
+		want     int
+	}{
+		{"muted", "2", nil, http.StatusNoContent},
+		{"invalid id", "abc", nil, http.StatusBadRequest},
+		{"own id", "1", nil, http.StatusBadRequest},
+		{"already muted", "2", store.ErrConflict, http.StatusConflict},
+		{"unknown user", "2", store.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutes := &fakeMutes{err: tt.storeErr}
+			app := newTestApplication(t, store.Storage{Mutes: mutes})
+
+			w := httptest.NewRecorder()
+			r := newTestRequest(http.MethodPut, "/v1/users/"+tt.userID+"/mute", &store.User{ID: 1}, map[string]string{"userID": tt.userID})
+
+			app.muteUserHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+
+			if tt.want == http.StatusNoContent && (len(mutes.muted) != 1 || mutes.muted[0] != [2]int64{1, 2}) {
+				t.Errorf("muted %v, want [[1 2]]", mutes.muted)
+			}
+		})
+	}
+}
+
+func TestUnmuteUserHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		storeErr error
+		want     int
+	}{
+		{"unmuted", "2", nil, http.StatusNoContent},
+		{"own id", "1", nil, http.StatusBadRequest},
+		{"not muted", "2", store.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, store.Storage{Mutes: &fakeMutes{err: tt.storeErr}})
+
+			w := httptest.NewRecorder()
+			r := newTestRequest(http.MethodDelete, "/v1/users/"+tt.userID+"/mute", &store.User{ID: 1}, map[string]string{"userID": tt.userID})
+
+			app.unmuteUserHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
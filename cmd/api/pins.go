@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// PinPost godoc
+//
+//	@Summary		Pin a post
+//	@Description	Pin one of the user's published posts at the top of their profile, above the posts pinned before. Up to 3 posts can be pinned.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{nil}		nil		"Post pinned"
+//	@Failure		400	{string}	error	"Post not published"
+//	@Failure		403	{string}	error	"Post of another user"
+//	@Failure		404	{string}	error	"Post not found"
+//	@Failure		409	{string}	error	"Post already pinned or pin limit reached"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/pin [put]
+func (app *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if post.UserID != user.ID {
+		app.forbiddenErrorResponse(w, r, errors.New("only the author can pin a post"))
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestErrorResponse(w, r, errors.New("only published posts can be pinned"))
+		return
+	}
+
+	if err := app.store.Pins.Pin(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, errors.New("post already pinned"))
+		case store.ErrTooManyPins:
+			app.conflictErrorResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpin a post
+//	@Description	Remove a post from the pins of the user with the auth token
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{nil}		nil		"Post unpinned"
+//	@Failure		404	{string}	error	"Post not pinned"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/pin [delete]
+func (app *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if err := app.store.Pins.Unpin(r.Context(), user.ID, post.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+type ReorderPinsPayload struct {
+	PostIDs []int64 `json:"post_ids" validate:"required,max=3,unique,dive,gte=1"`
+}
+
+// ReorderPins godoc
+//
+//	@Summary		Reorder pinned posts
+//	@Description	Set the order of the pinned posts of the user with the auth token
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			post_ids	body		[]int	true	"Every pinned post ID, in the new order"
+//	@Success		204			{nil}		nil		"Pins reordered"
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/pins [put]
+func (app *application) reorderPinsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ReorderPinsPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Pins.Reorder(r.Context(), user.ID, payload.PostIDs); err != nil {
+		switch err {
+		case store.ErrPinsMismatch:
+			app.badRequestErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
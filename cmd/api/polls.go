@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// maxPollDuration is how long a poll can stay open
+const maxPollDuration = time.Hour * 24 * 30
+
+type CreatePollPayload struct {
+	Options        []string  `json:"options" validate:"min=2,max=4,unique,dive,required,max=100"`
+	MultipleChoice bool      `json:"multiple_choice"`
+	HideResults    bool      `json:"hide_results"`
+	ExpiresAt      time.Time `json:"expires_at" validate:"required"`
+}
+
+// newPoll checks the poll of a post published at publishedAt and builds it.
+func newPoll(payload *CreatePollPayload, publishedAt time.Time) (*store.Poll, error) {
+	if !payload.ExpiresAt.After(publishedAt) {
+		return nil, errors.New("poll must expire after the post is published")
+	}
+
+	if payload.ExpiresAt.Sub(publishedAt) > maxPollDuration {
+		return nil, fmt.Errorf("poll can't stay open for more than %d days", int(maxPollDuration.Hours()/24))
+	}
+
+	poll := &store.Poll{
+		MultipleChoice: payload.MultipleChoice,
+		HideResults:    payload.HideResults,
+		ExpiresAt:      payload.ExpiresAt.Format(time.RFC3339),
+	}
+
+	for _, text := range payload.Options {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, errors.New("poll options can't be blank")
+		}
+
+		poll.Options = append(poll.Options, store.PollOption{Text: text})
+	}
+
+	return poll, nil
+}
+
+type PollVotePayload struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=4,unique,dive,gte=1"`
+}
+
+// VotePoll godoc
+//
+//	@Summary		Vote in a poll
+//	@Description	Vote for one option of a post's poll, or several for multiple choice polls. Each user votes once.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			option_ids	body		[]int	true	"IDs of the options voted for"
+//	@Success		201			{object}	store.Poll
+//	@Failure		400			{string}	error	"Invalid body or poll ended"
+//	@Failure		404			{string}	error	"Post or poll not found"
+//	@Failure		409			{string}	error	"Already voted"
+//	@Failure		500			{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/poll/votes [post]
+func (app *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	var payload PollVotePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	if post.Poll == nil {
+		app.notFoundErrorResponse(w, r, errors.New("post has no poll"))
+		return
+	}
+
+	if post.Status != store.PostStatusPublished {
+		app.badRequestErrorResponse(w, r, errors.New("post is not published"))
+		return
+	}
+
+	if post.Poll.Expired {
+		app.badRequestErrorResponse(w, r, errors.New("poll has ended"))
+		return
+	}
+
+	if !post.Poll.MultipleChoice && len(payload.OptionIDs) > 1 {
+		app.badRequestErrorResponse(w, r, errors.New("poll only allows one choice"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Polls.Vote(ctx, post.ID, user.ID, payload.OptionIDs); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, errors.New("already voted in this poll"))
+		case store.ErrNotFound:
+			app.badRequestErrorResponse(w, r, errors.New("option not part of this poll"))
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the tallies are revealed once the user voted
+	updated, err := app.store.Posts.GetByID(ctx, post.ID, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, updated.Poll); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/tiskae/go-social/internal/content"
 	"github.com/tiskae/go-social/internal/store"
 )
 
@@ -15,10 +18,23 @@ type PostKey string
 
 const postKey PostKey = "post"
 
+var errMediaNotAttachable = errors.New("media not found or already attached to a post")
+
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title           string             `json:"title" validate:"required,max=100"`
+	Content         string             `json:"content" validate:"required,max=1000"`
+	ContentFormat   string             `json:"content_format" validate:"omitempty,oneof=plain markdown"`
+	Tags            []string           `json:"tags"`
+	Status          string             `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt       *time.Time         `json:"publish_at" validate:"required_if=Status scheduled,excluded_unless=Status scheduled"`
+	Visibility      string             `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	QuotedPostID    *int64             `json:"quoted_post_id" validate:"omitempty,gte=1"`
+	InReplyToPostID *int64             `json:"in_reply_to_post_id" validate:"omitempty,gte=1"`
+	MediaIDs        []int64            `json:"media_ids" validate:"omitempty,max=4,unique,dive,gte=1"`
+	Poll            *CreatePollPayload `json:"poll"`
+	ContentWarning  string             `json:"content_warning" validate:"max=200"`
+	Sensitive       bool               `json:"sensitive"`
+	CommentPolicy   string             `json:"comment_policy" validate:"omitempty,oneof=everyone followers mentioned closed"`
 }
 
 // CreatePost godoc
@@ -30,10 +46,21 @@ type CreatePostPayload struct {
 //	@Produce		json
 //	@Param			title	body		string		true	"Post title"	maxlength(100)
 //	@Param			content	body		string		true	"Post content"	maxlength(1000)
-//	@Param			tags	body		[]string	false	"Post tags"
-//	@Success		201		{object}	store.Post
-//	@Failure		400		{string}	error	"Invalid body"
-//	@Failure		500		{string}	error	"Internal server error"
+//	@Param			content_format	body		string		false	"How the content is written: plain (default) or markdown"
+//	@Param			tags		body		[]string	false	"Post tags, merged with the #hashtags found in the content"
+//	@Param			status		body		string		false	"Post status: draft, scheduled or published (default)"
+//	@Param			publish_at	body		string		false	"When to publish a scheduled post (RFC 3339)"
+//	@Param			visibility		body		string		false	"Who can see the post: public (default), followers or mentioned"
+//	@Param			quoted_post_id	body		int			false	"ID of the post being quoted"
+//	@Param			in_reply_to_post_id	body		int		false	"ID of the post being replied to"
+//	@Param			media_ids		body		[]int		false	"IDs of uploaded media to attach, up to 4"
+//	@Param			poll			body		CreatePollPayload	false	"Poll with 2 to 4 options"
+//	@Param			content_warning	body		string		false	"Warning shown in place of the content until it is expanded"	maxlength(200)
+//	@Param			sensitive		body		bool		false	"Whether the attached media are sensitive"
+//	@Param			comment_policy	body		string		false	"Who can comment: everyone (default), followers, mentioned or closed"
+//	@Success		201				{object}	store.Post
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		500			{string}	error	"Internal server error"
 //	@Router			/posts [post]
 func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request) {
 	var payload CreatePostPayload
@@ -49,22 +76,117 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if payload.PublishAt != nil && !payload.PublishAt.After(time.Now()) {
+		app.badRequestErrorResponse(w, r, errors.New("publish_at must be in the future"))
+		return
+	}
+
 	user := getUserFromContext(r)
 
 	post := store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+		Title:           payload.Title,
+		Content:         payload.Content,
+		ContentFormat:   payload.ContentFormat,
+		Tags:            content.MergeTags(payload.Tags, content.FindHashtags(payload.Content)),
+		UserID:          user.ID,
+		Status:          payload.Status,
+		Visibility:      payload.Visibility,
+		QuotedPostID:    payload.QuotedPostID,
+		InReplyToPostID: payload.InReplyToPostID,
+		ContentWarning:  strings.TrimSpace(payload.ContentWarning),
+		Sensitive:       payload.Sensitive,
+		CommentPolicy:   payload.CommentPolicy,
+	}
+
+	publishedAt := time.Now()
+	if payload.PublishAt != nil {
+		publishAt := payload.PublishAt.Format(time.RFC3339)
+		post.PublishAt = &publishAt
+		publishedAt = *payload.PublishAt
+	}
+
+	if payload.Poll != nil {
+		post.Poll, err = newPoll(payload.Poll, publishedAt)
+		if err != nil {
+			app.badRequestErrorResponse(w, r, err)
+			return
+		}
 	}
 
 	ctx := r.Context()
 
-	if err := app.store.Posts.Create(ctx, &post); err != nil {
+	// users can only quote posts they can see
+	if post.QuotedPostID != nil {
+		quoted, err := app.store.Posts.GetByID(ctx, *post.QuotedPostID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestErrorResponse(w, r, errors.New("quoted post not found"))
+			default:
+				app.internalServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		quoted.QuotedPost = nil
+		post.QuotedPost = &quoted
+	}
+
+	// users can only reply to published posts they can see
+	if post.InReplyToPostID != nil {
+		parent, err := app.store.Posts.GetByID(ctx, *post.InReplyToPostID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestErrorResponse(w, r, errors.New("post replied to not found"))
+			default:
+				app.internalServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if parent.Status != store.PostStatusPublished {
+			app.badRequestErrorResponse(w, r, errors.New("post replied to not found"))
+			return
+		}
+	}
+
+	// media can only be attached once, by the user who uploaded them
+	if len(payload.MediaIDs) > 0 {
+		post.Attachments, err = app.store.Media.GetUnattached(ctx, user.ID, payload.MediaIDs)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err)
+			return
+		}
+
+		if len(post.Attachments) != len(payload.MediaIDs) {
+			app.badRequestErrorResponse(w, r, errMediaNotAttachable)
+			return
+		}
+	}
+
+	post.Mentions, err = app.resolveMentions(ctx, post.Content)
+	if err != nil {
 		app.internalServerErrorResponse(w, r, err)
 		return
 	}
 
+	if err := app.store.Posts.Create(ctx, &post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// the media got attached to another post meanwhile
+			app.badRequestErrorResponse(w, r, errMediaNotAttachable)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// previews are fetched in the background and show up once ready
+	app.enqueueUnfurl(content.FindLinks(post.Content))
+
+	app.fanOutPost(&post)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerErrorResponse(w, r, err)
 		return
@@ -77,7 +199,8 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Description	Fetches a post by ID
 //	@Tags			posts
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			include	query		string	false	"Set to comments to include the first page of comments"
 //	@Success		200	{object}	store.Post
 //	@Failure		400	{string}	error	"Invalid post ID"
 //	@Failure		404	{string}	error	"post not found"
@@ -132,16 +255,206 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	app.removePostFromTimelines(getPostFromCtx(r))
+
 	if err = app.jsonResponse(w, http.StatusOK, map[string]string{"message": "post deleted successfully!"}); err != nil {
 		// handling failed JSON write
 		app.internalServerErrorResponse(w, r, err)
 	}
 }
 
+// RestorePost godoc
+//
+//	@Summary		Restore a deleted post
+//	@Description	Restore a post deleted by its owner within the trash retention period (30 days)
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	store.Post
+//	@Failure		400	{string}	error	"Invalid post ID"
+//	@Failure		404	{string}	error	"Post not found"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/posts/{id}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+
+	// handling invalid or empty postID
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("post id is required as a valid integer"))
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	deletedAfter := time.Now().Add(-app.config.posts.trashRetention)
+
+	// only the owner can restore, and only while the post is still in the trash
+	if err := app.store.Posts.Restore(ctx, postID, user.ID, deletedAfter); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	post, err := app.store.Posts.GetByID(ctx, postID, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	app.fanOutPost(&post)
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetDeletedPosts godoc
+//
+//	@Summary		List deleted posts
+//	@Description	List soft-deleted posts still in the trash (moderators only)
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"How many posts to return"
+//	@Param			offset	query		int		false	"Offset to start from"
+//	@Param			sort	query		string	false	"Whether to sort by deletion time in ascending (asc) or descending(desc, default)"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{string}	error	"Invalid query params, or feed filters (cursor, tags, search, since, until)"
+//	@Failure		403		{string}	error	"Forbidden"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Router			/posts/deleted [get]
+func (app *application) getDeletedPostsHandler(w http.ResponseWriter, r *http.Request) {
+	lq := store.PaginatedListQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	lq, err := lq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(lq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	posts, err := app.store.Posts.GetDeleted(r.Context(), lq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetDrafts godoc
+//
+//	@Summary		List my drafts
+//	@Description	List the draft and scheduled posts of the user with the auth token
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"How many posts to return"
+//	@Param			offset	query		int		false	"Offset to start from"
+//	@Param			sort	query		string	false	"Whether to sort in ascending (asc) or descending(desc, default)"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{string}	error	"Invalid query params, or feed filters (cursor, tags, search, since, until)"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Router			/posts/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	lq := store.PaginatedListQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	lq, err := lq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(lq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, err := app.store.Posts.GetDrafts(r.Context(), user.ID, lq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// PublishPost godoc
+//
+//	@Summary		Publish a post
+//	@Description	Publish a draft or scheduled post right away
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	store.Post
+//	@Failure		400	{string}	error	"Invalid post ID"
+//	@Failure		404	{string}	error	"Post not found"
+//	@Failure		409	{string}	error	"Post already published"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/posts/{id}/publish [post]
+func (app *application) publishPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	// only the author can see an unpublished post, so anyone else gets a 404
+	if post.UserID != user.ID {
+		app.notFoundErrorResponse(w, r, errors.New("post not found"))
+		return
+	}
+
+	if post.Status == store.PostStatusPublished {
+		app.conflictErrorResponse(w, r, errors.New("post is already published"))
+		return
+	}
+
+	if err := app.store.Posts.Publish(r.Context(), post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.fanOutPost(post)
+
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
 type UpdatePostPayload struct {
-	Title   *string   `json:"title" validate:"omitempty,max=100"`
-	Content *string   `json:"content" validate:"omitempty,max=1000"`
-	Tags    *[]string `json:"tags" validate:"omitempty,dive,required"`
+	Title         *string   `json:"title" validate:"omitempty,max=100"`
+	Content       *string   `json:"content" validate:"omitempty,max=1000"`
+	Tags          *[]string `json:"tags" validate:"omitempty,dive,required"`
+	Visibility    *string   `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	ContentFormat *string   `json:"content_format" validate:"omitempty,oneof=plain markdown"`
+	// ContentWarning is removed when set to an empty string
+	ContentWarning *string `json:"content_warning" validate:"omitempty,max=200"`
+	Sensitive      *bool   `json:"sensitive"`
+	CommentPolicy  *string `json:"comment_policy" validate:"omitempty,oneof=everyone followers mentioned closed"`
 }
 
 // godoc UpdatePost
@@ -154,10 +467,16 @@ type UpdatePostPayload struct {
 //	@Param			id		path		int			true	"Post ID"
 //	@Param			title	body		string		false	"Post title"	maxlength(100)
 //	@Param			content	body		string		false	"Post body"		maxlength(1000)
-//	@Param			tags	body		[]string	false	"Post tags"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{string}	error	"Invalid body"
-//	@Failure		404		{string}	error	"Post not found"
+//	@Param			tags		body		[]string	false	"Post tags, merged with the #hashtags found in the content"
+//	@Param			visibility	body		string		false	"Who can see the post: public, followers or mentioned"
+//	@Param			content_format	body		string		false	"How the content is written: plain or markdown"
+//	@Param			content_warning	body		string		false	"Warning shown in place of the content, empty to remove it"	maxlength(200)
+//	@Param			sensitive		body		bool		false	"Whether the attached media are sensitive"
+//	@Param			comment_policy	body		string		false	"Who can comment: everyone, followers, mentioned or closed"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{string}	error	"Invalid body"
+//	@Failure		403			{string}	error	"Content warning forced by a moderator, or changed by someone else than the author"
+//	@Failure		404			{string}	error	"Post not found"
 //	@Failure		500		{string}	error	"Internal server error"
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
@@ -194,6 +513,10 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestErrorResponse(w, r, err)
 	}
 
+	// explicit tags are kept when only the content changes, while hashtags
+	// follow the content
+	explicitTags := removeTags(post.Tags, content.FindHashtags(post.Content))
+
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
@@ -201,7 +524,45 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Content = *payload.Content
 	}
 	if payload.Tags != nil {
-		post.Tags = *payload.Tags
+		explicitTags = *payload.Tags
+	}
+	post.Tags = content.MergeTags(explicitTags, content.FindHashtags(post.Content))
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+	if payload.ContentFormat != nil {
+		post.ContentFormat = *payload.ContentFormat
+	}
+
+	// moderators editing someone else's post go through the moderation
+	// endpoints to set a content warning, so that it is forced and audited
+	if (payload.ContentWarning != nil || payload.Sensitive != nil) && post.UserID != getUserFromContext(r).ID {
+		app.forbiddenErrorResponse(w, r, errors.New("only the author can change the content warning, moderators use /content-warning"))
+		return
+	}
+
+	contentWarning, sensitive := post.ContentWarning, post.Sensitive
+	if payload.ContentWarning != nil {
+		post.ContentWarning = strings.TrimSpace(*payload.ContentWarning)
+	}
+	if payload.Sensitive != nil {
+		post.Sensitive = *payload.Sensitive
+	}
+	if payload.CommentPolicy != nil {
+		post.CommentPolicy = *payload.CommentPolicy
+	}
+
+	// a content warning forced by a moderator can only be changed through the
+	// moderation endpoints, which keep an audit record
+	if post.ContentWarningForced && (post.ContentWarning != contentWarning || (sensitive && !post.Sensitive)) {
+		app.forbiddenErrorResponse(w, r, errors.New("content warning was set by a moderator"))
+		return
+	}
+
+	post.Mentions, err = app.resolveMentions(r.Context(), post.Content)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
 	}
 
 	err = app.store.Posts.UpdateOne(r.Context(), postID, post)
@@ -218,6 +579,16 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	if payload.Content != nil {
+		links := content.FindLinks(post.Content)
+		app.enqueueUnfurl(links)
+
+		// previews of links that are gone are dropped, new ones come later
+		post.LinkPreviews = slices.DeleteFunc(post.LinkPreviews, func(preview store.LinkPreview) bool {
+			return !slices.Contains(links, preview.URL)
+		})
+	}
+
 	if err = app.jsonResponse(w, http.StatusOK, post); err != nil {
 		// handling failed JSON write
 		app.internalServerErrorResponse(w, r, err)
@@ -240,7 +611,11 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		post, err := app.store.Posts.GetByID(r.Context(), postID)
+		user := getUserFromContext(r)
+
+		// posts the user can't see (unpublished, followers-only or mentioned-only)
+		// are reported as not found rather than forbidden
+		post, err := app.store.Posts.GetByID(r.Context(), postID, user.ID)
 
 		if err != nil {
 			switch {
@@ -252,16 +627,17 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		comments, err := app.store.Comments.GetByPostID(r.Context(), postID)
+		// comments are only loaded when asked for, with ?include=comments
+		if slices.Contains(strings.Split(r.URL.Query().Get("include"), ","), "comments") {
+			post.Comments, _, err = app.store.Comments.GetByPostID(r.Context(), postID, defaultCommentsQuery())
 
-		if err != nil {
-			app.internalServerErrorResponse(w, r, err)
-			return
+			if err != nil {
+				app.internalServerErrorResponse(w, r, err)
+				return
+			}
 		}
 
-		post.Comments = comments
-
-		// injecting fetched post (with comments) into the request context
+		// injecting fetched post (and comments if asked for) into the request context
 		newCtx := context.WithValue(r.Context(), postKey, &post)
 
 		// calling the next handlerFunc
@@ -269,6 +645,19 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// removeTags returns the tags not in removed.
+func removeTags(tags []string, removed []string) []string {
+	kept := []string{}
+
+	for _, tag := range tags {
+		if !slices.Contains(removed, tag) {
+			kept = append(kept, tag)
+		}
+	}
+
+	return kept
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post := r.Context().Value(postKey).(*store.Post)
 	return post
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// Moderators can edit other users' posts, but content warnings they set go
+// through the moderation endpoints so that they are forced and audited.
+func TestUpdatePostHandlerContentWarningByModerator(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"set content warning", `{"content_warning": "spoilers"}`},
+		{"clear content warning", `{"content_warning": ""}`},
+		{"unmark sensitive", `{"sensitive": false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the store is left empty: the request must be rejected before
+			// anything is written
+			app := newTestApplication(t, store.Storage{})
+
+			post := &store.Post{ID: 5, UserID: 1, ContentWarning: "spoilers", Sensitive: true}
+
+			w := httptest.NewRecorder()
+			r := newTestRequest(http.MethodPatch, "/v1/posts/5", &store.User{ID: 2}, map[string]string{"postID": "5"})
+			r.Body = io.NopCloser(strings.NewReader(tt.body))
+			r = r.WithContext(context.WithValue(r.Context(), postKey, post))
+
+			app.updatePostHandler(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// GetPreferences godoc
+//
+//	@Summary		Fetch my preferences
+//	@Description	Fetch the preferences of the user with the auth token
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.Preferences
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/preferences [get]
+func (app *application) getPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	prefs, err := app.store.Users.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+type UpdatePreferencesPayload struct {
+	ExpandContentWarnings *bool `json:"expand_content_warnings"`
+}
+
+// UpdatePreferences godoc
+//
+//	@Summary		Update my preferences
+//	@Description	Update the preferences of the user with the auth token. Fields left out are kept.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			expand_content_warnings	body		bool	false	"Show posts with a content warning or sensitive media expanded instead of hidden behind a click"
+//	@Success		200						{object}	store.Preferences
+//	@Failure		400						{string}	error	"Invalid body"
+//	@Failure		500						{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/preferences [patch]
+func (app *application) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdatePreferencesPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	prefs, err := app.store.Users.GetPreferences(ctx, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if payload.ExpandContentWarnings != nil {
+		prefs.ExpandContentWarnings = *payload.ExpandContentWarnings
+	}
+
+	if err := app.store.Users.UpdatePreferences(ctx, user.ID, &prefs); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, prefs); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// Repost godoc
+//
+//	@Summary		Repost a post
+//	@Description	Share a public post with the followers of the user with the auth token
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{nil}		nil		"Post reposted"
+//	@Failure		400	{string}	error	"Post can't be reposted"
+//	@Failure		404	{string}	error	"Post not found"
+//	@Failure		409	{string}	error	"Post already reposted"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/posts/{id}/repost [put]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	// reposting a restricted post would leak it to the reposter's followers
+	if post.Status != store.PostStatusPublished || post.Visibility != store.PostVisibilityPublic {
+		app.badRequestErrorResponse(w, r, errors.New("only published public posts can be reposted"))
+		return
+	}
+
+	err := app.store.Reposts.Create(r.Context(), user.ID, post.ID)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.fanOutRepost(user.ID, post.ID, time.Now())
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// UndoRepost godoc
+//
+//	@Summary		Undo a repost
+//	@Description	Remove the repost of a post by the user with the auth token
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int		true	"Post ID"
+//	@Success		204	{nil}		nil		"Repost removed"
+//	@Failure		404	{string}	error	"Repost not found"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/posts/{id}/repost [delete]
+func (app *application) undoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	err := app.store.Reposts.Delete(r.Context(), user.ID, post.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
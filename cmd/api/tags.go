@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tiskae/go-social/internal/content"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// trendingWindows are the sliding windows trending tags can be computed over.
+// Within a window, a post's weight halves every quarter of the window.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": time.Hour * 24,
+	"7d":  time.Hour * 24 * 7,
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		List posts by tag
+//	@Description	List the posts carrying a tag, newest first
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag, with or without the leading #"
+//	@Param			limit	query		int		false	"How many posts to return"
+//	@Param			cursor	query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{string}	error	"Invalid tag or query params"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	cq := store.PaginatedCursorQuery{
+		Limit: 20,
+	}
+
+	cq, err = cq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	posts, next, err := app.store.Posts.GetByTag(r.Context(), tag, user.ID, cq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, posts, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		List trending tags
+//	@Description	List the tags trending in public posts over a time window
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"Time window: 1h, 24h (default) or 7d"
+//	@Param			limit	query		int		false	"How many tags to return (max 20)"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{string}	error	"Invalid query params"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	window := qs.Get("window")
+	if window == "" {
+		window = "24h"
+	}
+
+	if _, ok := trendingWindows[window]; !ok {
+		app.badRequestErrorResponse(w, r, errors.New("window must be one of 1h, 24h or 7d"))
+		return
+	}
+
+	limit := 10
+	if l := qs.Get("limit"); l != "" {
+		var err error
+
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 20 {
+			app.badRequestErrorResponse(w, r, errors.New("limit must be an integer between 1 and 20"))
+			return
+		}
+	}
+
+	tags, err := app.getTrendingTags(r.Context(), window)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// getTrendingTags computes the top 20 trending tags over window, going
+// through the cache when Redis is enabled.
+func (app *application) getTrendingTags(ctx context.Context, window string) ([]store.TrendingTag, error) {
+	duration := trendingWindows[window]
+
+	compute := func() ([]store.TrendingTag, error) {
+		return app.store.Tags.GetTrending(ctx, time.Now().Add(-duration), duration/4, 20)
+	}
+
+	if !app.config.redisCfg.enabled {
+		return compute()
+	}
+
+	tags, err := app.cacheStorage.Tags.GetTrending(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags == nil {
+		tags, err = compute()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = app.cacheStorage.Tags.SetTrending(ctx, window, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+func tagFromURL(r *http.Request) (string, error) {
+	raw, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		return "", err
+	}
+
+	tag := content.NormalizeTag(raw)
+	if tag == "" {
+		return "", errors.New("tag is not valid")
+	}
+
+	return tag, nil
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follow a tag
+//	@Description	Follow a tag so that posts carrying it show up in the home feed
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag, with or without the leading #"
+//	@Success		204	{nil}		nil		"Tag followed"
+//	@Failure		400	{string}	error	"Invalid tag"
+//	@Failure		409	{string}	error	"Tag already followed"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/tags/{tag}/follow [put]
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Tags.Follow(r.Context(), user.ID, tag); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollow a tag
+//	@Description	Stop following a tag
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag, with or without the leading #"
+//	@Success		204	{nil}		nil		"Tag unfollowed"
+//	@Failure		400	{string}	error	"Invalid tag"
+//	@Failure		404	{string}	error	"Tag not followed"
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/tags/{tag}/follow [delete]
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tagFromURL(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Tags.Unfollow(r.Context(), user.ID, tag); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
+
+// GetFollowedTags godoc
+//
+//	@Summary		List followed tags
+//	@Description	List the tags followed by the user with the auth token
+//	@Tags			tags
+//	@Produce		json
+//	@Success		200	{object}	[]store.FollowedTag
+//	@Failure		500	{string}	error	"Internal server error"
+//	@Router			/tags/followed [get]
+func (app *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tags, err := app.store.Tags.GetFollowed(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
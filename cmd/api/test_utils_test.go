@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tiskae/go-social/internal/store"
+	"go.uber.org/zap"
+)
+
+func newTestApplication(t *testing.T, storage store.Storage) *application {
+	t.Helper()
+
+	return &application{
+		store:  storage,
+		logger: zap.NewNop().Sugar(),
+	}
+}
+
+// newTestRequest builds a request as the router and auth middleware would
+// hand it to a handler: made by user, with the given URL params.
+func newTestRequest(method, target string, user *store.User, params map[string]string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+
+	routeCtx := chi.NewRouteContext()
+	for key, value := range params {
+		routeCtx.URLParams.Add(key, value)
+	}
+
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)
+	ctx = context.WithValue(ctx, userKey, user)
+
+	return r.WithContext(ctx)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// GetPostThread godoc
+//
+//	@Summary		Fetch a post's thread
+//	@Description	Fetch the conversation around a post: the posts it replies to, root first, and a tree of its replies, oldest first. Posts hidden from the user are left out, along with the replies below them.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id		path		int	true	"Post ID"
+//	@Param			depth	query		int	false	"Levels of replies to include, 1 to 10 (default 5)"
+//	@Param			limit	query		int	false	"Maximum number of replies, 1 to 200 (default 100)"
+//	@Success		200		{object}	store.Thread
+//	@Failure		400		{string}	error	"Invalid query"
+//	@Failure		404		{string}	error	"Post not found"
+//	@Failure		500		{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/thread [get]
+func (app *application) getPostThreadHandler(w http.ResponseWriter, r *http.Request) {
+	tq := store.ThreadQuery{
+		Depth: 5,
+		Limit: 100,
+	}
+
+	tq, err := tq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(tq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+
+	thread, err := app.store.Posts.GetThread(r.Context(), *post, user.ID, tq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, thread); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
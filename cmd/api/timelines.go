@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/tiskae/go-social/internal/store"
+	"github.com/tiskae/go-social/internal/store/cache"
+)
+
+// Home timelines are kept in Redis when it's enabled: new posts and reposts
+// are written to the timelines of the followers of their author (fan-out on
+// write), except for accounts with many followers whose activity is merged in
+// when a timeline is read (fan-out on read). A timeline is built from the
+// database on its first read, and posts no longer meant for the reader are
+// filtered out when loading them, so Redis only serves as an index.
+
+// getHomeTimeline returns a page of the user's home feed, read from their
+// timeline when possible and from the database otherwise.
+func (app *application) getHomeTimeline(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, *store.Cursor, *store.Cursor, error) {
+	// timelines are only ordered by time, filtering them is left to the database
+	if !app.config.redisCfg.enabled || fq.Offset > 0 || fq.Search != "" || len(fq.Tags) > 0 {
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	exists, err := app.cacheStorage.Timelines.Exists(ctx, userID)
+	if err != nil {
+		app.logger.Warnw("failed to read timeline, falling back to the database", "user_id", userID, "error", err.Error())
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	if !exists {
+		entries, err := app.store.Timelines.GetFeedEntries(ctx, userID, cache.TimelineMaxLength)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// an empty feed is as cheap to read from the database
+		if len(entries) == 0 {
+			return app.store.Posts.GetUserFeed(ctx, userID, fq)
+		}
+
+		if err := app.cacheStorage.Timelines.Seed(ctx, userID, entries); err != nil {
+			app.logger.Warnw("failed to build timeline, falling back to the database", "user_id", userID, "error", err.Error())
+			return app.store.Posts.GetUserFeed(ctx, userID, fq)
+		}
+	}
+
+	backward := fq.Cursor != nil && fq.Cursor.Before
+
+	// previous pages are read in the reverse order, then flipped back
+	tr := store.TimelineRange{
+		After: fq.Cursor,
+		Desc:  (fq.Sort == "desc") != backward,
+		Since: fq.Since,
+		Until: fq.Until,
+		// fetching one extra entry tells whether there is another page
+		Limit: fq.Limit + 1,
+	}
+
+	entries, err := app.cacheStorage.Timelines.Range(ctx, userID, tr)
+	if err != nil {
+		app.logger.Warnw("failed to read timeline, falling back to the database", "user_id", userID, "error", err.Error())
+		return app.store.Posts.GetUserFeed(ctx, userID, fq)
+	}
+
+	// a full timeline has dropped its oldest entries, so pages reaching past
+	// them are read from the database: descending ones that ran out of
+	// entries, and ascending ones starting there
+	if len(entries) < tr.Limit || !tr.Desc {
+		truncatedAt, err := app.cacheStorage.Timelines.TruncatedAt(ctx, userID)
+		if err != nil {
+			app.logger.Warnw("failed to read timeline, falling back to the database", "user_id", userID, "error", err.Error())
+			return app.store.Posts.GetUserFeed(ctx, userID, fq)
+		}
+
+		if truncatedAt != nil && timelineReaches(tr, *truncatedAt) {
+			return app.store.Posts.GetUserFeed(ctx, userID, fq)
+		}
+	}
+
+	celebrityEntries, err := app.store.Timelines.GetCelebrityEntries(ctx, userID, app.config.timelines.celebrityFollowers, tr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	entries = mergeTimelineEntries(entries, celebrityEntries, tr.Desc)
+
+	hasMore := len(entries) > fq.Limit
+	if hasMore {
+		entries = entries[:fq.Limit]
+	}
+
+	if backward {
+		slices.Reverse(entries)
+	}
+
+	next, prev := store.FeedCursors(fq, entries, hasMore)
+
+	// pages can come out shorter than the limit, as entries of posts that
+	// were deleted or hidden meanwhile are dropped here
+	feed, err := app.store.Posts.GetFeedPosts(ctx, userID, entries)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return feed, next, prev, nil
+}
+
+// timelineReaches tells whether the range covers entries as old as t, going
+// by where it starts when ascending and where it ends when descending.
+func timelineReaches(tr store.TimelineRange, t time.Time) bool {
+	oldest := tr.Since
+	if !tr.Desc && tr.After != nil && (oldest == nil || tr.After.CreatedAt.After(*oldest)) {
+		oldest = &tr.After.CreatedAt
+	}
+
+	return oldest == nil || !oldest.After(t)
+}
+
+// mergeTimelineEntries merges two lists of entries sorted the same way,
+// keeping the first entry of each post.
+func mergeTimelineEntries(a []store.TimelineEntry, b []store.TimelineEntry, desc bool) []store.TimelineEntry {
+	before := func(x store.TimelineEntry, y store.TimelineEntry) bool {
+		if !x.ActivityAt.Equal(y.ActivityAt) {
+			return x.ActivityAt.Before(y.ActivityAt) != desc
+		}
+
+		return (x.PostID < y.PostID) != desc
+	}
+
+	merged := make([]store.TimelineEntry, 0, len(a)+len(b))
+	seen := map[int64]bool{}
+
+	for len(a) > 0 || len(b) > 0 {
+		var entry store.TimelineEntry
+
+		if len(b) == 0 || (len(a) > 0 && before(a[0], b[0])) {
+			entry, a = a[0], a[1:]
+		} else {
+			entry, b = b[0], b[1:]
+		}
+
+		if !seen[entry.PostID] {
+			seen[entry.PostID] = true
+			merged = append(merged, entry)
+		}
+	}
+
+	return merged
+}
+
+// updateTimelines runs fn in the background when timelines are kept, so that
+// requests don't wait on fanning out to every follower.
+func (app *application) updateTimelines(name string, fn func(ctx context.Context) error) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.timelines.updateTimeout)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			app.logger.Errorw("failed to update timelines", "update", name, "error", err.Error())
+		}
+	}()
+}
+
+// isCelebrity tells whether the user has too many followers for their
+// activity to be fanned out.
+func (app *application) isCelebrity(ctx context.Context, userID int64) (bool, error) {
+	followers, err := app.store.Timelines.CountFollowers(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return followers >= app.config.timelines.celebrityFollowers, nil
+}
+
+// fanOut adds the entry to the timelines of the users who see the activity
+// of the user on a post with the given tags.
+func (app *application) fanOut(ctx context.Context, userID int64, tags []string, entry store.TimelineEntry) error {
+	celebrity, err := app.isCelebrity(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	audience, err := app.store.Timelines.GetAudience(ctx, userID, tags, !celebrity)
+	if err != nil {
+		return err
+	}
+
+	return app.cacheStorage.Timelines.Add(ctx, audience, entry)
+}
+
+// fanOutPost adds a newly published post to the timelines it belongs to.
+func (app *application) fanOutPost(post *store.Post) {
+	if post.Status != store.PostStatusPublished {
+		return
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	userID, tags := post.UserID, post.Tags
+	entry := store.TimelineEntry{PostID: post.ID, ActivityAt: createdAt}
+
+	app.updateTimelines("fan out post", func(ctx context.Context) error {
+		return app.fanOut(ctx, userID, tags, entry)
+	})
+}
+
+// fanOutRepost moves a post reposted by the user to the top of the timelines
+// of the user's followers.
+func (app *application) fanOutRepost(userID int64, postID int64, repostedAt time.Time) {
+	entry := store.TimelineEntry{PostID: postID, ActivityAt: repostedAt}
+
+	app.updateTimelines("fan out repost", func(ctx context.Context) error {
+		return app.fanOut(ctx, userID, nil, entry)
+	})
+}
+
+// removePostFromTimelines removes a deleted post from the timelines it was
+// fanned out to. Timelines it got to through reposts keep it until it's
+// filtered out on read.
+func (app *application) removePostFromTimelines(post *store.Post) {
+	postID, userID, tags := post.ID, post.UserID, post.Tags
+
+	app.updateTimelines("remove post", func(ctx context.Context) error {
+		audience, err := app.store.Timelines.GetAudience(ctx, userID, tags, true)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Remove(ctx, audience, postID)
+	})
+}
+
+// followTimeline adds the latest posts of a newly followed user to the
+// follower's timeline, unless they're read on demand.
+func (app *application) followTimeline(followerID int64, userID int64) {
+	app.updateTimelines("follow", func(ctx context.Context) error {
+		celebrity, err := app.isCelebrity(ctx, userID)
+		if err != nil || celebrity {
+			return err
+		}
+
+		entries, err := app.store.Timelines.GetRecentByUser(ctx, userID, cache.TimelineMaxLength)
+		if err != nil {
+			return err
+		}
+
+		return app.cacheStorage.Timelines.Add(ctx, []int64{followerID}, entries...)
+	})
+}
+
+// unfollowTimeline removes the posts of an unfollowed user from the former
+// follower's timeline.
+func (app *application) unfollowTimeline(followerID int64, userID int64) {
+	app.updateTimelines("unfollow", func(ctx context.Context) error {
+		entries, err := app.store.Timelines.GetRecentByUser(ctx, userID, cache.TimelineMaxLength)
+		if err != nil {
+			return err
+		}
+
+		postIDs := make([]int64, len(entries))
+		for i, entry := range entries {
+			postIDs[i] = entry.PostID
+		}
+
+		return app.cacheStorage.Timelines.Remove(ctx, []int64{followerID}, postIDs...)
+	})
+}
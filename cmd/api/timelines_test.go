@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+func TestTimelineReaches(t *testing.T) {
+	truncatedAt := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	before, after := truncatedAt.Add(-time.Hour), truncatedAt.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		tr   store.TimelineRange
+		want bool
+	}{
+		{"descending without bounds", store.TimelineRange{Desc: true}, true},
+		{"descending since before", store.TimelineRange{Desc: true, Since: &before}, true},
+		{"descending since the oldest entry", store.TimelineRange{Desc: true, Since: &truncatedAt}, true},
+		{"descending since after", store.TimelineRange{Desc: true, Since: &after}, false},
+		{"descending cursor after", store.TimelineRange{Desc: true, After: &store.Cursor{CreatedAt: after}}, true},
+		{"ascending without bounds", store.TimelineRange{}, true},
+		{"ascending cursor before", store.TimelineRange{After: &store.Cursor{CreatedAt: before}}, true},
+		{"ascending cursor after", store.TimelineRange{After: &store.Cursor{CreatedAt: after}}, false},
+		{"ascending since after", store.TimelineRange{Since: &after}, false},
+		{"ascending cursor before since after", store.TimelineRange{Since: &after, After: &store.Cursor{CreatedAt: before}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timelineReaches(tt.tr, truncatedAt); got != tt.want {
+				t.Errorf("timelineReaches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// unfurlBacklogBatchSize bounds how many pending links one run of the backfill
+// job queues.
+const unfurlBacklogBatchSize = 50
+
+// enqueueUnfurl queues links without blocking. Links that don't fit in a full
+// queue are picked up later by the backfill job.
+func (app *application) enqueueUnfurl(links []string) {
+	for _, link := range links {
+		select {
+		case app.unfurlQueue <- link:
+		default:
+			return
+		}
+	}
+}
+
+// runUnfurlWorker fetches the previews of queued links until ctx is cancelled.
+func (app *application) runUnfurlWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case link := <-app.unfurlQueue:
+			if err := app.unfurlLink(ctx, link); err != nil {
+				app.logger.Errorw("failed to save link preview", "url", link, "error", err.Error())
+			}
+		}
+	}
+}
+
+func (app *application) unfurlLink(ctx context.Context, link string) error {
+	// a popular link is only fetched once
+	fetched, err := app.store.LinkPreviews.IsFetched(ctx, link)
+	if err != nil || fetched {
+		return err
+	}
+
+	preview := store.LinkPreview{URL: link}
+
+	page, err := app.unfurler.Fetch(ctx, link)
+	switch {
+	case ctx.Err() != nil:
+		// shutting down, the backfill job retries on the next start
+		return nil
+	case err != nil:
+		app.logger.Infow("could not unfurl link", "url", link, "error", err.Error())
+		preview.Failed = true
+	case page.Title == "":
+		// a preview without a title isn't worth showing
+		preview.Failed = true
+	default:
+		preview.Title = page.Title
+		preview.Description = page.Description
+		preview.ImageURL = page.ImageURL
+		preview.SiteName = page.SiteName
+	}
+
+	return app.store.LinkPreviews.Save(ctx, &preview)
+}
+
+// unfurlPendingLinks queues the links that never got fetched, such as the
+// ones dropped from a full queue or queued before a restart.
+func (app *application) unfurlPendingLinks(ctx context.Context) error {
+	links, err := app.store.LinkPreviews.GetUnfetched(ctx, unfurlBacklogBatchSize)
+	if err != nil {
+		return err
+	}
+
+	app.enqueueUnfurl(links)
+
+	return nil
+}
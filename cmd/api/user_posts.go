@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// GetUserPosts godoc
+//
+//	@Summary		List a user's posts
+//	@Description	List the published posts of a user visible to the user with the auth token, newest first. Unless filters are given, the first page starts with the user's pinned posts, in their order, on top of the requested limit.
+//	@Tags			users
+//	@Produce		json
+//	@Param			id				path		int		true	"User ID"
+//	@Param			limit			query		int		false	"Page size, 1 to 20 (default 20)"
+//	@Param			cursor			query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Param			tags			query		string	false	"Comma-separated tags the posts must all carry"
+//	@Param			search			query		string	false	"Text to look for in titles and contents"
+//	@Param			since			query		string	false	"Only posts from this time on: RFC 3339, 2006-01-02 15:04:05 (UTC) or a duration ago such as 24h or 7d"
+//	@Param			until			query		string	false	"Only posts up to this time, in the same formats as since"
+//	@Param			include_replies	query		bool	false	"Include the user's replies (default false)"
+//	@Param			include_reposts	query		bool	false	"Include the posts the user reposted (default true)"
+//	@Success		200				{object}	[]store.PostWithMetadata
+//	@Failure		400				{string}	error	"Invalid query"
+//	@Failure		404				{string}	error	"User not found"
+//	@Failure		500				{string}	error	"Internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, errors.New("user id must be a valid integer"))
+		return
+	}
+
+	uq := store.UserPostsQuery{
+		Limit:          20,
+		Tags:           []string{},
+		IncludeReposts: true,
+	}
+
+	uq, err = uq.Parse(r)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(uq); err != nil {
+		app.badRequestErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.getUser(ctx, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundErrorResponse(w, r, err)
+		default:
+			app.internalServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	viewer := getUserFromContext(r)
+
+	posts := []store.PostWithMetadata{}
+
+	if uq.Cursor == nil && !uq.Filtered() {
+		posts, err = app.store.Posts.GetPinned(ctx, userID, viewer.ID)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	userPosts, next, err := app.store.Posts.GetByUser(ctx, userID, viewer.ID, uq)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err)
+		return
+	}
+
+	posts = append(posts, userPosts...)
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, posts, next); err != nil {
+		app.internalServerErrorResponse(w, r, err)
+	}
+}
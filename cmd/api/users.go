@@ -94,6 +94,7 @@ func (app *application) getUserByIDHandler(w http.ResponseWriter, r *http.Reques
 //	@Produce		json
 //	@Success		204	{nil}		nil		"User followed successfully"
 //	@Failure		400	{string}	error	"Invalid user ID"
+//	@Failure		403	{string}	error	"User blocked"
 //	@Failure		404	{string}	error	"User not found"
 //	@Failure		500	{string}	error	"Internal server error"
 //	@Router			/users/{user_id}/follow [put]
@@ -115,6 +116,9 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		case store.ErrConflict:
 			app.conflictErrorResponse(w, r, err)
 			return
+		case store.ErrBlocked:
+			app.forbiddenErrorResponse(w, r, err)
+			return
 		case store.ErrNotFound:
 			app.notFoundErrorResponse(w, r, err)
 			return
@@ -124,6 +128,8 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	app.followTimeline(followerID, userID)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerErrorResponse(w, r, err)
 	}
@@ -163,6 +169,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	app.unfollowTimeline(followerID, userID)
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerErrorResponse(w, r, err)
 	}
@@ -0,0 +1,7 @@
+ALTER TABLE comments
+DROP CONSTRAINT IF EXISTS fk_post;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE posts
+DROP COLUMN deleted_at;
@@ -0,0 +1,20 @@
+ALTER TABLE posts
+ADD COLUMN deleted_at timestamp(0)
+with
+    time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
+
+-- Remove comments orphaned by earlier hard deletes before adding the FK
+DELETE FROM comments
+WHERE
+    post_id NOT IN (
+        SELECT
+            id
+        FROM
+            posts
+    );
+
+ALTER TABLE comments ADD CONSTRAINT fk_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reposts;
+
+ALTER TABLE posts
+DROP COLUMN quoted_post_id;
@@ -0,0 +1,16 @@
+ALTER TABLE posts
+ADD COLUMN quoted_post_id bigint REFERENCES posts (id) ON DELETE SET NULL;
+
+CREATE TABLE
+    IF NOT EXISTS reposts (
+        user_id bigint NOT NULL,
+        post_id bigint NOT NULL,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            PRIMARY KEY (user_id, post_id),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_reposts_post_id ON reposts (post_id);
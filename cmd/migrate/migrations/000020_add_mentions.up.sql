@@ -0,0 +1,25 @@
+-- Mentions in a post have no comment_id, mentions in a comment also carry
+-- the post the comment belongs to
+CREATE TABLE
+    IF NOT EXISTS mentions (
+        id bigserial PRIMARY KEY,
+        post_id bigint NOT NULL,
+        comment_id bigint,
+        user_id bigint NOT NULL,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+            FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE,
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+    );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_post_user ON mentions (post_id, user_id)
+WHERE
+    comment_id IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_comment_user ON mentions (comment_id, user_id)
+WHERE
+    comment_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mentions_user_created_at ON mentions (user_id, created_at DESC, id DESC);
@@ -0,0 +1,2 @@
+-- Tags normalization can't be undone
+DROP INDEX IF EXISTS idx_posts_created_at;
@@ -0,0 +1,16 @@
+-- Best-effort normalization of the tags stored before hashtag parsing; new
+-- tags are normalized (NFKC + case folding) by the API
+UPDATE posts
+SET
+    tags = ARRAY (
+        SELECT DISTINCT
+            lower(trim(BOTH '#' FROM trim(tag)))
+        FROM
+            unnest(tags) AS tag
+        WHERE
+            trim(BOTH '#' FROM trim(tag)) <> ''
+    )
+WHERE
+    tags IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at DESC);
@@ -0,0 +1,5 @@
+ALTER TABLE posts
+DROP COLUMN content_html;
+
+ALTER TABLE posts
+DROP COLUMN content_format;
@@ -0,0 +1,7 @@
+ALTER TABLE posts
+ADD COLUMN content_format VARCHAR(20) NOT NULL DEFAULT 'plain' CHECK (content_format IN ('plain', 'markdown'));
+
+-- rendered HTML of the content, filled on write; NULL for posts written
+-- before rendering existed, until the API backfills it on startup
+ALTER TABLE posts
+ADD COLUMN content_html TEXT;
@@ -0,0 +1,27 @@
+CREATE TABLE
+    IF NOT EXISTS media (
+        id bigserial PRIMARY KEY,
+        user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        -- media is uploaded before the post it is attached to exists, and
+        -- goes back to being unattached when that post is purged
+        post_id bigint REFERENCES posts (id) ON DELETE SET NULL,
+        position SMALLINT NOT NULL DEFAULT 0,
+        blob_key TEXT NOT NULL,
+        thumbnail_key TEXT NOT NULL,
+        url TEXT NOT NULL,
+        thumbnail_url TEXT NOT NULL,
+        content_type VARCHAR(50) NOT NULL,
+        size bigint NOT NULL,
+        width INT NOT NULL,
+        height INT NOT NULL,
+        description TEXT NOT NULL DEFAULT '',
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW ()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_media_post_id ON media (post_id, position);
+
+CREATE INDEX IF NOT EXISTS idx_media_unattached ON media (created_at)
+WHERE
+    post_id IS NULL;
@@ -0,0 +1,25 @@
+CREATE TABLE
+    IF NOT EXISTS link_previews (
+        url TEXT PRIMARY KEY,
+        title TEXT NOT NULL DEFAULT '',
+        description TEXT NOT NULL DEFAULT '',
+        image_url TEXT NOT NULL DEFAULT '',
+        site_name TEXT NOT NULL DEFAULT '',
+        -- failed fetches are kept so that the URL isn't fetched again for
+        -- every post linking to it
+        failed BOOLEAN NOT NULL DEFAULT FALSE,
+        fetched_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW ()
+    );
+
+CREATE TABLE
+    IF NOT EXISTS post_links (
+        post_id bigint NOT NULL,
+        url TEXT NOT NULL,
+        position SMALLINT NOT NULL,
+        PRIMARY KEY (post_id, url),
+        FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_post_links_url ON post_links (url);
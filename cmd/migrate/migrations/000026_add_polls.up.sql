@@ -0,0 +1,38 @@
+CREATE TABLE
+    IF NOT EXISTS polls (
+        post_id bigint PRIMARY KEY REFERENCES posts (id) ON DELETE CASCADE,
+        multiple_choice BOOLEAN NOT NULL DEFAULT FALSE,
+        -- hides the tallies from voters until they vote or the poll ends
+        hide_results BOOLEAN NOT NULL DEFAULT FALSE,
+        expires_at timestamp(0)
+        with
+            time zone NOT NULL
+    );
+
+CREATE TABLE
+    IF NOT EXISTS poll_options (
+        id bigserial PRIMARY KEY,
+        post_id bigint NOT NULL REFERENCES polls (post_id) ON DELETE CASCADE,
+        position SMALLINT NOT NULL,
+        text VARCHAR(100) NOT NULL,
+        UNIQUE (post_id, position)
+    );
+
+-- one row per voter makes "one vote per user" hold for multiple choice polls
+-- too, where a single vote picks several options
+CREATE TABLE
+    IF NOT EXISTS poll_voters (
+        post_id bigint NOT NULL REFERENCES polls (post_id) ON DELETE CASCADE,
+        user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            PRIMARY KEY (post_id, user_id)
+    );
+
+CREATE TABLE
+    IF NOT EXISTS poll_votes (
+        option_id bigint NOT NULL REFERENCES poll_options (id) ON DELETE CASCADE,
+        user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        PRIMARY KEY (option_id, user_id)
+    );
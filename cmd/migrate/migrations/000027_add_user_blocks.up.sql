@@ -0,0 +1,15 @@
+CREATE TABLE
+    IF NOT EXISTS user_blocks (
+        user_id bigint NOT NULL,
+        blocked_id bigint NOT NULL,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            PRIMARY KEY (user_id, blocked_id),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE,
+            CHECK (user_id <> blocked_id)
+    );
+
+-- blocks hide posts both ways, so they're also looked up by blocked user
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
@@ -0,0 +1,2 @@
+ALTER TABLE posts
+DROP COLUMN in_reply_to_post_id;
@@ -0,0 +1,7 @@
+-- replies outlive purged parents, becoming the root of their own thread
+ALTER TABLE posts
+ADD COLUMN in_reply_to_post_id bigint REFERENCES posts (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_in_reply_to_post_id ON posts (in_reply_to_post_id, created_at)
+WHERE
+    in_reply_to_post_id IS NOT NULL;
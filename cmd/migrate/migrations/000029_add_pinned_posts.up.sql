@@ -0,0 +1,15 @@
+CREATE TABLE
+    IF NOT EXISTS pinned_posts (
+        user_id bigint NOT NULL,
+        post_id bigint NOT NULL,
+        -- pins are listed by ascending position
+        position SMALLINT NOT NULL,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            PRIMARY KEY (user_id, post_id),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_created_at ON posts (user_id, created_at DESC, id DESC);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS moderation_actions;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS expand_content_warnings;
+
+ALTER TABLE media
+DROP COLUMN IF EXISTS sensitive;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS content_warning,
+DROP COLUMN IF EXISTS sensitive,
+DROP COLUMN IF EXISTS content_warning_forced;
@@ -0,0 +1,28 @@
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS content_warning VARCHAR(200) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS sensitive BOOLEAN NOT NULL DEFAULT FALSE,
+-- set when a moderator put the content warning, the author can't lift it
+ADD COLUMN IF NOT EXISTS content_warning_forced BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE media
+ADD COLUMN IF NOT EXISTS sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS expand_content_warnings BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE
+    IF NOT EXISTS moderation_actions (
+        id bigserial PRIMARY KEY,
+        moderator_id bigint REFERENCES users (id) ON DELETE SET NULL,
+        post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+        action VARCHAR(50) NOT NULL,
+        reason VARCHAR(500) NOT NULL,
+        -- the post's content warning before and after the action
+        previous_value TEXT NOT NULL DEFAULT '',
+        new_value TEXT NOT NULL DEFAULT '',
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW ()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_post_id ON moderation_actions (post_id);
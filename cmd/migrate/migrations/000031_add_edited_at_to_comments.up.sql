@@ -0,0 +1,4 @@
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS edited_at timestamp(0)
+with
+    time zone;
@@ -0,0 +1,11 @@
+-- deleted comments with replies are kept as tombstones, so parents are only
+-- hard-deleted along with their post
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS parent_comment_id bigint REFERENCES comments (id) ON DELETE CASCADE,
+ADD COLUMN IF NOT EXISTS deleted_at timestamp(0)
+with
+    time zone;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_comment_id ON comments (parent_comment_id, created_at, id)
+WHERE
+    parent_comment_id IS NOT NULL;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_comments_post_reactions_count;
+
+DROP INDEX IF EXISTS idx_comments_post_created_at;
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS reactions_count;
+
+DROP TABLE IF EXISTS comment_reactions;
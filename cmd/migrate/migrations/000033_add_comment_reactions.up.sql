@@ -0,0 +1,23 @@
+-- one reaction per user and comment, which they can change
+CREATE TABLE
+    IF NOT EXISTS comment_reactions (
+        comment_id bigint NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+        user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        emoji VARCHAR(32) NOT NULL,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            PRIMARY KEY (comment_id, user_id)
+    );
+
+-- kept in sync with comment_reactions so comments can be paged by it
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS reactions_count INT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_created_at ON comments (post_id, created_at, id)
+WHERE
+    parent_comment_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_reactions_count ON comments (post_id, reactions_count DESC, created_at DESC, id DESC)
+WHERE
+    parent_comment_id IS NULL;
@@ -0,0 +1,14 @@
+DELETE FROM comment_reactions
+WHERE
+    deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_comment_reactions_comment_created_at;
+
+DROP INDEX IF EXISTS idx_comment_reactions_comment_user;
+
+ALTER TABLE comment_reactions
+DROP COLUMN IF EXISTS deleted_at,
+DROP COLUMN IF EXISTS id;
+
+ALTER TABLE comment_reactions
+ADD PRIMARY KEY (comment_id, user_id);
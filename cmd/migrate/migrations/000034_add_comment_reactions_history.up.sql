@@ -0,0 +1,17 @@
+-- removed reactions are kept with deleted_at set, so that comments can be
+-- ranked by their reactions at a given time
+ALTER TABLE comment_reactions
+DROP CONSTRAINT IF EXISTS comment_reactions_pkey;
+
+ALTER TABLE comment_reactions
+ADD COLUMN IF NOT EXISTS id bigserial PRIMARY KEY,
+ADD COLUMN IF NOT EXISTS deleted_at timestamp(0)
+with
+    time zone;
+
+-- one current reaction per user and comment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_comment_reactions_comment_user ON comment_reactions (comment_id, user_id)
+WHERE
+    deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comment_reactions_comment_created_at ON comment_reactions (comment_id, created_at);
@@ -0,0 +1,4 @@
+-- who can comment: everyone, followers of the author, users mentioned in the
+-- post, or nobody but the author
+ALTER TABLE posts
+ADD COLUMN comment_policy VARCHAR(20) NOT NULL DEFAULT 'everyone' CHECK (comment_policy IN ('everyone', 'followers', 'mentioned', 'closed'));
@@ -0,0 +1,12 @@
+CREATE TABLE
+    IF NOT EXISTS user_mutes (
+        user_id bigint NOT NULL,
+        muted_id bigint NOT NULL,
+        created_at timestamp(0)
+        with
+            time zone NOT NULL DEFAULT NOW (),
+            PRIMARY KEY (user_id, muted_id),
+            FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+            FOREIGN KEY (muted_id) REFERENCES users (id) ON DELETE CASCADE,
+            CHECK (user_id <> muted_id)
+    );
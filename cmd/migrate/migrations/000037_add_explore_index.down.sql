@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_posts_public_created_at;
@@ -0,0 +1,6 @@
+-- the explore feed lists recent public posts across all users
+CREATE INDEX IF NOT EXISTS idx_posts_public_created_at ON posts (created_at DESC, id DESC)
+WHERE
+    visibility = 'public'
+    AND status = 'published'
+    AND deleted_at IS NULL;
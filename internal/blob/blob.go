@@ -0,0 +1,19 @@
+// Package blob stores binary objects such as uploaded media.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type BlobStore interface {
+	// Put stores data under key, replacing any existing blob.
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get opens the blob stored under key along with its content type. The
+	// caller must close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,233 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+	testBucket    = "media"
+)
+
+var authRegex = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([^,]+), Signature=([0-9a-f]{64})$`)
+
+// fakeS3 is an in-memory bucket that checks the signature of every request
+// against what it actually received.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := verifySignature(r, body); err != "" {
+		http.Error(w, err, http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Write(data)
+	case http.MethodDelete:
+		if _, ok := f.objects[key]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verifySignature recomputes the Signature Version 4 of r from the request
+// as received, returning what's wrong with it if anything.
+func verifySignature(r *http.Request, body []byte) string {
+	match := authRegex.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return "malformed authorization header"
+	}
+
+	accessKey, date, region, signedHeaders, signature := match[1], match[2], match[3], match[4], match[5]
+
+	if accessKey != testAccessKey || region != testRegion {
+		return "wrong credential"
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return "credential date doesn't match x-amz-date"
+	}
+
+	payloadHash := sha256.Sum256(body)
+	payloadHex := hex.EncodeToString(payloadHash[:])
+	if r.Header.Get("X-Amz-Content-Sha256") != payloadHex {
+		return "payload hash doesn't match the body"
+	}
+
+	if signedHeaders != "host;x-amz-content-sha256;x-amz-date" {
+		return "unexpected signed headers " + signedHeaders
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		"host:" + r.Host + "\nx-amz-content-sha256:" + payloadHex + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHex,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+testSecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	if hex.EncodeToString(hmacSHA256(key, stringToSign)) != signature {
+		return "signature mismatch"
+	}
+
+	return ""
+}
+
+func newTestS3Store(t *testing.T, secretKey string) (*S3Store, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Store(S3Config{
+		Endpoint:  server.URL + "/",
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: secretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, fake
+}
+
+func TestS3Store(t *testing.T) {
+	s, fake := newTestS3Store(t, testSecretKey)
+	ctx := context.Background()
+
+	for _, key := range []string{"posts/1/image.png", "posts/1/a b+c (1).png", "posts/1/café~.gif", "empty"} {
+		t.Run(key, func(t *testing.T) {
+			data := []byte("data of " + key)
+			if key == "empty" {
+				data = nil
+			}
+
+			if err := s.Put(ctx, key, "image/png", data); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			if !bytes.Equal(fake.objects[key], data) {
+				t.Fatalf("stored %q, want %q", fake.objects[key], data)
+			}
+
+			body, contentType, err := s.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+
+			got, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, data) || contentType != "image/png" {
+				t.Errorf("Get() = %q, %q, want %q, image/png", got, contentType, data)
+			}
+
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			if _, _, err := s.Get(ctx, key); err != ErrNotFound {
+				t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3StoreDeleteMissing(t *testing.T) {
+	s, _ := newTestS3Store(t, testSecretKey)
+
+	if err := s.Delete(context.Background(), "missing"); err != nil {
+		t.Errorf("Delete() of a missing blob error = %v, want nil", err)
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	s, _ := newTestS3Store(t, "wrong secret")
+
+	err := s.Put(context.Background(), "key", "image/png", []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "signature mismatch") {
+		t.Errorf("Put() with a wrong secret error = %v, want a 403 with the response message", err)
+	}
+}
+
+func TestNewS3Store(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  S3Config
+	}{
+		{"missing scheme", S3Config{Endpoint: "localhost:9000", Bucket: "media"}},
+		{"missing host", S3Config{Endpoint: "http://", Bucket: "media"}},
+		{"missing bucket", S3Config{Endpoint: "http://localhost:9000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewS3Store(tt.cfg); err == nil {
+				t.Error("NewS3Store() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package content
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxTags caps how many tags a post can carry, explicit and parsed combined
+const MaxTags = 20
+
+// MaxTagLength is the longest tag kept, in Unicode code points
+const MaxTagLength = 50
+
+var hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}\p{M}_#&/])#([\p{L}\p{N}\p{M}_]+)`)
+
+var folder = cases.Fold()
+
+// NormalizeTag brings a tag to its canonical form so that "#Go", "go" and
+// "ＧＯ" are the same tag: NFKC normalized, case folded and without the
+// leading "#". It returns "" for tags that aren't valid.
+func NormalizeTag(tag string) string {
+	tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	tag = norm.NFKC.String(folder.String(norm.NFKC.String(tag)))
+
+	if tag == "" || len([]rune(tag)) > MaxTagLength {
+		return ""
+	}
+
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r) && r != '_' && r != '-' {
+			return ""
+		}
+	}
+
+	return tag
+}
+
+// FindHashtags returns the normalized #hashtags in text, in order of
+// appearance. Purely numeric hashtags such as "#1" are not tags.
+func FindHashtags(text string) []string {
+	tags := []string{}
+
+	for _, match := range hashtagRegex.FindAllStringSubmatch(text, -1) {
+		if strings.TrimFunc(match[1], unicode.IsNumber) == "" {
+			continue
+		}
+
+		if tag := NormalizeTag(match[1]); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// MergeTags normalizes and deduplicates the given tag lists, keeping the
+// order of first appearance, and caps the result at MaxTags.
+func MergeTags(lists ...[]string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+
+	for _, list := range lists {
+		for _, tag := range list {
+			tag = NormalizeTag(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+
+			seen[tag] = true
+			tags = append(tags, tag)
+
+			if len(tags) == MaxTags {
+				return tags
+			}
+		}
+	}
+
+	return tags
+}
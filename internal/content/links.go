@@ -0,0 +1,93 @@
+package content
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLinks caps how many links of a text get a preview
+const MaxLinks = 4
+
+// FindLinks returns the distinct http(s) links in text, bare or written as
+// Markdown links, normalized with NormalizeURL and in order of appearance.
+func FindLinks(text string) []string {
+	links := []string{}
+	seen := map[string]bool{}
+
+	for i := 0; i < len(text) && len(links) < MaxLinks; {
+		rest := text[i:]
+
+		if startsAutolink(rest) && (i == 0 || !isWordByte(text[i-1])) {
+			link := autolinkAt(rest)
+
+			if normalized, ok := NormalizeURL(link); ok && !seen[normalized] {
+				seen[normalized] = true
+				links = append(links, normalized)
+			}
+
+			i += max(len(link), 1)
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		i += size
+	}
+
+	return links
+}
+
+// NormalizeURL brings an http(s) URL to a canonical form, so that links to
+// the same page share a preview: lowercase scheme and host, no default port,
+// no fragment, no utm_* tracking parameters and sorted query parameters.
+func NormalizeURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" || u.User != nil {
+		return "", false
+	}
+
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	// IPv6 literals keep their brackets
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	u.Host = host
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+
+	// Encode sorts the parameters by key
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String(), true
+}
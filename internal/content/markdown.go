@@ -0,0 +1,247 @@
+package content
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// AllowedTags are the only HTML tags the renderers ever output. Raw HTML in
+// the source is escaped, never passed through, and links only keep http,
+// https and mailto URLs, with rel="nofollow noreferrer".
+var AllowedTags = []string{
+	"p", "br", "h1", "h2", "h3", "h4", "h5", "h6", "strong", "em", "del",
+	"code", "pre", "blockquote", "ul", "ol", "li", "a", "hr",
+}
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(
+			extension.Strikethrough,
+			extension.NewLinkify(
+				extension.WithLinkifyAllowedProtocols([]string{"http:", "https:"}),
+			),
+		),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(rawHTMLEscaper{}, 100)),
+		),
+	)
+
+	policy = newPolicy()
+
+	autolinkPrefix = []string{"https://", "http://"}
+)
+
+// newPolicy is the allowlist every rendered post goes through.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(AllowedTags...)
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.AllowRelativeURLs(false)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+
+	return p
+}
+
+// Render renders text written in format as sanitized HTML.
+func Render(text, format string) string {
+	if format == FormatMarkdown {
+		return RenderMarkdown(text)
+	}
+
+	return RenderPlain(text)
+}
+
+// RenderPlain renders plain text as HTML: paragraphs split on blank lines,
+// line breaks kept and bare URLs linked.
+func RenderPlain(text string) string {
+	var b strings.Builder
+
+	for _, paragraph := range splitParagraphs(normalizeNewlines(text)) {
+		b.WriteString("<p>")
+		for i, line := range paragraph {
+			if i > 0 {
+				b.WriteString("<br>")
+			}
+			writeAutolinked(&b, line)
+		}
+		b.WriteString("</p>")
+	}
+
+	return policy.Sanitize(b.String())
+}
+
+// RenderMarkdown renders CommonMark with strikethrough and bare URLs as HTML,
+// then strips whatever the policy doesn't allow.
+func RenderMarkdown(text string) string {
+	var b bytes.Buffer
+
+	if err := markdown.Convert([]byte(text), &b); err != nil {
+		return RenderPlain(text)
+	}
+
+	return strings.TrimSpace(policy.Sanitize(b.String()))
+}
+
+// rawHTMLEscaper renders raw HTML found in Markdown as text rather than
+// dropping it, so that writing <b> shows up as typed.
+type rawHTMLEscaper struct{}
+
+func (rawHTMLEscaper) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+}
+
+func renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+
+	segments := node.(*ast.RawHTML).Segments
+	for i := 0; i < segments.Len(); i++ {
+		segment := segments.At(i)
+		_, _ = w.WriteString(html.EscapeString(string(segment.Value(source))))
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+func renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	block := node.(*ast.HTMLBlock)
+
+	var text strings.Builder
+	for i := 0; i < block.Lines().Len(); i++ {
+		line := block.Lines().At(i)
+		text.Write(line.Value(source))
+	}
+	if block.HasClosure() {
+		text.Write(block.ClosureLine.Value(source))
+	}
+
+	_, _ = w.WriteString("<p>")
+	_, _ = w.WriteString(html.EscapeString(strings.TrimRight(text.String(), "\n")))
+	_, _ = w.WriteString("</p>\n")
+
+	return ast.WalkContinue, nil
+}
+
+func normalizeNewlines(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+func splitParagraphs(text string) [][]string {
+	paragraphs := [][]string{}
+	current := []string{}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, current)
+				current = []string{}
+			}
+			continue
+		}
+
+		current = append(current, line)
+	}
+
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, current)
+	}
+
+	return paragraphs
+}
+
+// safeURL only accepts absolute http(s) and mailto URLs.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+	default:
+		return "", false
+	}
+
+	return u.String(), true
+}
+
+// writeAutolinked escapes s, linking the bare URLs in it.
+func writeAutolinked(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		if startsAutolink(rest) && (i == 0 || !isWordByte(s[i-1])) {
+			link := autolinkAt(rest)
+			if safe, ok := safeURL(link); ok {
+				b.WriteString(`<a href="`)
+				b.WriteString(html.EscapeString(safe))
+				b.WriteString(`">`)
+				b.WriteString(html.EscapeString(link))
+				b.WriteString("</a>")
+				i += len(link)
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		b.WriteString(html.EscapeString(rest[:size]))
+		i += size
+	}
+}
+
+func startsAutolink(s string) bool {
+	for _, prefix := range autolinkPrefix {
+		if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// autolinkAt returns the URL at the start of s, up to the first whitespace
+// and without trailing punctuation.
+func autolinkAt(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '<' || r == '>' || r == '"'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+
+	return strings.TrimRight(s[:end], ".,;:!?)]*_~'")
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
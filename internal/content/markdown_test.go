@@ -0,0 +1,196 @@
+package content
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const rel = `rel="nofollow noreferrer"`
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// raw HTML
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"event handler", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>"},
+		{"html in heading", "# <h1>title</h1>", "<h1>&lt;h1&gt;title&lt;/h1&gt;</h1>"},
+		{"html in blockquote", "> > <b>quote</b>", "<blockquote>\n<blockquote>\n<p>&lt;b&gt;quote&lt;/b&gt;</p>\n</blockquote>\n</blockquote>"},
+		{"html in list", "- <i>item</i>", "<ul>\n<li>&lt;i&gt;item&lt;/i&gt;</li>\n</ul>"},
+		{"html block", "<div>\nhi\n</div>", "<p>&lt;div&gt;\nhi\n&lt;/div&gt;</p>"},
+		{"html comment", "<!-- hidden -->", "<p>&lt;!-- hidden --&gt;</p>"},
+		{"raw anchor", `<a href="javascript:x">y</a>`, "<p>&lt;a href=&#34;javascript:x&#34;&gt;y&lt;/a&gt;</p>"},
+		{"escaped brackets", `\<b\>`, "<p>&lt;b&gt;</p>"},
+		{"image", "![x](http://example.com/x.png)", "<p></p>"},
+
+		// link schemes
+		{"http link", "[x](http://example.com)", `<p><a href="http://example.com" ` + rel + `>x</a></p>`},
+		{"mailto link", "[x](mailto:a@example.com)", `<p><a href="mailto:a@example.com" ` + rel + `>x</a></p>`},
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>"},
+		{"mixed case javascript link", "[x](JaVaScRiPt:alert(1))", "<p>x</p>"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>"},
+		{"vbscript link", "[x](vbscript:msgbox)", "<p>x</p>"},
+		{"protocol relative link", "[x](//example.com)", "<p>x</p>"},
+		{"relative link", "[x](/admin)", "<p>x</p>"},
+		{"javascript next to a safe link", "[a](http://example.com) [b](javascript:1)", `<p><a href="http://example.com" ` + rel + `>a</a> b</p>`},
+
+		// attribute injection
+		{"quote in link host", `[x](http://example.com"onclick=alert(1))`, "<p>x</p>"},
+		{"quote in link query", `[x](http://example.com/?q="><script>)`, `<p><a href="http://example.com/?q=%22%3E%3Cscript%3E" ` + rel + `>x</a></p>`},
+		{"quote in mailto", `[x](mailto:"onclick=alert)`, `<p><a href="mailto:%22onclick=alert" ` + rel + `>x</a></p>`},
+		{"quote after bare url", `https://example.com/"onmouseover=alert(1)`, `<p><a href="https://example.com/%22onmouseover=alert(1)" ` + rel + `>https://example.com/&#34;onmouseover=alert(1)</a></p>`},
+		{"ampersand in bare url", "https://example.com/?a=1&b=2", `<p><a href="https://example.com/?a=1&amp;b=2" ` + rel + `>https://example.com/?a=1&amp;b=2</a></p>`},
+
+		// nesting and code
+		{"nested emphasis", "*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>"},
+		{"code in emphasis", "**bold *em `<b>` em* bold**", "<p><strong>bold <em>em <code>&lt;b&gt;</code> em</em> bold</strong></p>"},
+		{"emphasis in code", "`**not bold**`", "<p><code>**not bold**</code></p>"},
+		{"html in code span", "`<script>`", "<p><code>&lt;script&gt;</code></p>"},
+		{"html in fenced code", "```\n<script>\n```", "<pre><code>&lt;script&gt;\n</code></pre>"},
+		{"language of fenced code", "```js\nx\n```", "<pre><code>x\n</code></pre>"},
+		{"strikethrough", "~~gone~~", "<p><del>gone</del></p>"},
+		{"snake case", "snake_case_word", "<p>snake_case_word</p>"},
+		{"emphasis in link", "[**x**](http://example.com)", `<p><a href="http://example.com" ` + rel + `><strong>x</strong></a></p>`},
+		{"bare url in link", "[https://b.com](http://a.com)", `<p><a href="http://a.com" ` + rel + `>https://b.com</a></p>`},
+		{"link in link", "[a [b](http://b.com)](http://a.com)", ""},
+		{"deep nesting", strings.Repeat("*a ", 20) + "x" + strings.Repeat(" a*", 20), ""},
+		{"deep blockquote", strings.Repeat(">", 200) + " x", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderMarkdown(tt.in)
+
+			if tt.want != "" && got != tt.want {
+				t.Errorf("RenderMarkdown(%q)\n got: %s\nwant: %s", tt.in, got, tt.want)
+			}
+
+			checkAllowedHTML(t, got)
+		})
+	}
+}
+
+func TestRenderPlain(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"raw html", "<b>hi</b>", "<p>&lt;b&gt;hi&lt;/b&gt;</p>"},
+		{"markdown left as is", "**hi** [x](javascript:alert(1))", "<p>**hi** [x](javascript:alert(1))</p>"},
+		{"line breaks", "a\nb\n\nc", "<p>a<br>b</p><p>c</p>"},
+		{"bare url", `see https://example.com/?a=1&b="x`, `<p>see <a href="https://example.com/?a=1&amp;b=" ` + rel + `>https://example.com/?a=1&amp;b=</a>&#34;x</p>`},
+		{"javascript url", "javascript:alert(1)", "<p>javascript:alert(1)</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderPlain(tt.in)
+
+			if got != tt.want {
+				t.Errorf("RenderPlain(%q)\n got: %s\nwant: %s", tt.in, got, tt.want)
+			}
+
+			checkAllowedHTML(t, got)
+		})
+	}
+}
+
+func FuzzRender(f *testing.F) {
+	seeds := []string{
+		"# title\n\n*a* **b** ~~c~~ `d`",
+		"[x](javascript:alert(1)) <script>alert(1)</script>",
+		"> quote\n\n- item\n1. item\n\n---",
+		"```\n<b>\n```",
+		`https://example.com/"onmouseover=alert(1) <a href="x">y</a>`,
+		"<div onclick=x>\n\n<img src=x onerror=y>",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		checkAllowedHTML(t, RenderMarkdown(text))
+		checkAllowedHTML(t, RenderPlain(text))
+	})
+}
+
+// checkAllowedHTML tokenizes the rendered HTML the way a browser would and
+// fails unless it only has allowed tags, properly nested, and links with
+// nothing but a safe href and rel.
+func checkAllowedHTML(t *testing.T, rendered string) {
+	t.Helper()
+
+	open := []string{}
+	z := html.NewTokenizer(strings.NewReader(rendered))
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if len(open) > 0 {
+				t.Errorf("unclosed %v in %s", open, rendered)
+			}
+			return
+
+		case html.CommentToken, html.DoctypeToken:
+			t.Errorf("unexpected %q in %s", z.Token().String(), rendered)
+
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			token := z.Token()
+			tag := token.Data
+
+			if !slices.Contains(AllowedTags, tag) {
+				t.Errorf("tag %q is not allowed in %s", tag, rendered)
+				continue
+			}
+
+			if token.Type == html.EndTagToken {
+				if len(open) == 0 || open[len(open)-1] != tag {
+					t.Errorf("unbalanced </%s> in %s", tag, rendered)
+					return
+				}
+				open = open[:len(open)-1]
+				continue
+			}
+
+			checkAttributes(t, token, rendered)
+
+			if tag == "br" || tag == "hr" {
+				continue
+			}
+
+			if tag == "a" && slices.Contains(open, "a") {
+				t.Errorf("nested link in %s", rendered)
+			}
+
+			open = append(open, tag)
+		}
+	}
+}
+
+func checkAttributes(t *testing.T, token html.Token, rendered string) {
+	t.Helper()
+
+	if token.Data != "a" {
+		if len(token.Attr) > 0 {
+			t.Errorf("tag %q has attributes %v in %s", token.Data, token.Attr, rendered)
+		}
+		return
+	}
+
+	if len(token.Attr) != 2 || token.Attr[0].Key != "href" || token.Attr[1].Key != "rel" ||
+		token.Attr[1].Val != "nofollow noreferrer" {
+		t.Errorf("link attributes %v are not allowed in %s", token.Attr, rendered)
+		return
+	}
+
+	href := strings.ToLower(token.Attr[0].Val)
+	if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") && !strings.HasPrefix(href, "mailto:") {
+		t.Errorf("link to %q is not allowed in %s", token.Attr[0].Val, rendered)
+	}
+}
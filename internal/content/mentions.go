@@ -0,0 +1,60 @@
+// Package content parses entities such as mentions out of user-written text
+package content
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// MaxMentions caps how many distinct users a single text can mention
+const MaxMentions = 10
+
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,100})`)
+
+// Mention is an @username found in a text. Start and End are offsets in
+// Unicode code points, End being exclusive and Start pointing at the "@".
+type Mention struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// FindMentions returns every @username in text, in order of appearance.
+func FindMentions(text string) []Mention {
+	mentions := []Mention{}
+
+	for _, loc := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		// loc[2]:loc[3] is the username, right after the "@"
+		start := utf8.RuneCountInString(text[:loc[2]-1])
+
+		mentions = append(mentions, Mention{
+			Username: text[loc[2]:loc[3]],
+			Start:    start,
+			End:      start + 1 + utf8.RuneCountInString(text[loc[2]:loc[3]]),
+		})
+	}
+
+	return mentions
+}
+
+// MentionedUsernames returns the distinct usernames mentioned in text, in
+// order of appearance and capped at MaxMentions.
+func MentionedUsernames(text string) []string {
+	seen := map[string]bool{}
+	usernames := []string{}
+
+	for _, mention := range FindMentions(text) {
+		if seen[mention.Username] {
+			continue
+		}
+
+		seen[mention.Username] = true
+		usernames = append(usernames, mention.Username)
+
+		if len(usernames) == MaxMentions {
+			break
+		}
+	}
+
+	return usernames
+}
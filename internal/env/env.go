@@ -45,3 +45,18 @@ func GetBool(envKey string, defaultVal bool) bool {
 
 	return boolValue
 }
+
+func GetFloat(envKey string, defaultVal float64) float64 {
+	value, exists := os.LookupEnv(envKey)
+
+	if !exists || value == "" {
+		return defaultVal
+	}
+
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultVal
+	}
+
+	return floatValue
+}
@@ -0,0 +1,61 @@
+package media
+
+// gifFrameCount counts the frames of a GIF by walking its blocks, without
+// decoding any of them. Counting stops at the trailer or where the data ends
+// or stops making sense, leaving it to the decoder to reject broken files.
+func gifFrameCount(data []byte) int {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0
+	}
+
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1)
+	}
+
+	frames := 0
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: label then data sub-blocks
+			i += 2
+		case 0x2C: // image descriptor, local color table then LZW code size
+			if i+10 > len(data) {
+				return frames
+			}
+
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i++
+
+			frames++
+		default: // trailer, or garbage
+			return frames
+		}
+
+		i = skipGIFSubBlocks(data, i)
+	}
+
+	return frames
+}
+
+// skipGIFSubBlocks returns the index following the data sub-blocks starting
+// at i, which end with an empty block.
+func skipGIFSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+
+		if size == 0 {
+			break
+		}
+
+		i += size
+	}
+
+	return i
+}
@@ -0,0 +1,76 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"testing"
+)
+
+func encodeGIF(t *testing.T, frames, width, height int) []byte {
+	t.Helper()
+
+	anim := &gif.GIF{}
+	for i := range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+		frame.Pix[0] = uint8(i)
+
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestGIFFrameCount(t *testing.T) {
+	for _, frames := range []int{1, 2, 37} {
+		if got := gifFrameCount(encodeGIF(t, frames, 16, 8)); got != frames {
+			t.Errorf("gifFrameCount() = %d, want %d", got, frames)
+		}
+	}
+
+	if got := gifFrameCount([]byte("GIF89a")); got != 0 {
+		t.Errorf("gifFrameCount() of a truncated header = %d, want 0", got)
+	}
+}
+
+func TestProcessImageGIFFrames(t *testing.T) {
+	tests := []struct {
+		name          string
+		frames        int
+		width, height int
+		wantErr       error
+	}{
+		{"short animation", 3, 16, 16, nil},
+		{"too many frames", MaxFrames + 1, 2, 2, ErrTooManyFrames},
+		{"too many pixels across frames", 11, 2000, 2000, ErrTooManyFrames},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ProcessImage(encodeGIF(t, tt.frames, tt.width, tt.height))
+			if err != tt.wantErr {
+				t.Fatalf("ProcessImage() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			anim, err := gif.DecodeAll(bytes.NewReader(img.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(anim.Image) != tt.frames {
+				t.Errorf("processed GIF has %d frames, want %d", len(anim.Image), tt.frames)
+			}
+		})
+	}
+}
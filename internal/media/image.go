@@ -0,0 +1,206 @@
+// Package media validates and processes uploaded media before it is stored.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxPixels bounds the decoded size of an image, so that small files
+	// can't expand to huge bitmaps in memory.
+	MaxPixels = 40_000_000
+	// MaxFrames bounds the number of frames of an animated GIF, whose
+	// frames together also have to fit in MaxPixels.
+	MaxFrames = 500
+	// ThumbnailSize is the largest side of a thumbnail, in pixels.
+	ThumbnailSize = 400
+	jpegQuality   = 90
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type, expected a JPEG, PNG or GIF image")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+	ErrTooManyFrames   = errors.New("animation has too many frames")
+)
+
+// Image is an uploaded image cleaned up for storage, along with its
+// thumbnail.
+type Image struct {
+	Data        []byte
+	ContentType string
+	// Ext is the file extension matching ContentType, dot included.
+	Ext       string
+	Width     int
+	Height    int
+	Thumbnail []byte
+	// ThumbnailContentType is image/jpeg for JPEG sources and image/png
+	// otherwise, preserving transparency.
+	ThumbnailContentType string
+	ThumbnailExt         string
+}
+
+// ProcessImage checks that data holds a supported image, going by its
+// content rather than any client-provided type, and re-encodes it. Only the
+// pixels survive re-encoding, which strips EXIF and other metadata such as
+// GPS coordinates; the EXIF orientation of JPEGs is applied first so photos
+// keep the right side up.
+func ProcessImage(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	// every frame of a GIF is decoded, so a long animation of small frames
+	// can take as much memory as one huge image
+	if contentType == "image/gif" {
+		frames := gifFrameCount(data)
+		if frames > MaxFrames || frames*cfg.Width*cfg.Height > MaxPixels {
+			return nil, ErrTooManyFrames
+		}
+	}
+
+	img := &Image{ContentType: contentType}
+
+	var (
+		frame image.Image
+		buf   bytes.Buffer
+	)
+
+	switch contentType {
+	case "image/jpeg":
+		frame, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		frame = applyOrientation(frame, exifOrientation(data))
+
+		err = jpeg.Encode(&buf, frame, &jpeg.Options{Quality: jpegQuality})
+		img.Ext = ".jpg"
+	case "image/png":
+		frame, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		err = png.Encode(&buf, frame)
+		img.Ext = ".png"
+	case "image/gif":
+		// every frame is kept so animations survive; comments and
+		// application extensions other than looping are dropped
+		var anim *gif.GIF
+		anim, err = gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		frame = anim.Image[0]
+
+		err = gif.EncodeAll(&buf, anim)
+		img.Ext = ".gif"
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	img.Data = buf.Bytes()
+	img.Width = frame.Bounds().Dx()
+	img.Height = frame.Bounds().Dy()
+
+	thumb := thumbnail(frame, ThumbnailSize)
+	buf = bytes.Buffer{}
+
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: jpegQuality})
+		img.ThumbnailContentType, img.ThumbnailExt = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		img.ThumbnailContentType, img.ThumbnailExt = "image/png", ".png"
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	img.Thumbnail = buf.Bytes()
+
+	return img, nil
+}
+
+// thumbnail scales src down to fit in a size×size square, averaging the
+// source pixels covered by each thumbnail pixel. Images already small
+// enough are only copied.
+func thumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	// working on premultiplied RGBA keeps transparent pixels from bleeding
+	// their color into the average
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	if tw == w && th == h {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+
+	for y := range th {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+
+		for x := range tw {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, bl, a, n int
+
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					bl += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,88 @@
+// Package ranking for scoring the posts of the ranked feed
+package ranking
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// Weights tune how much each signal counts in a post's score.
+type Weights struct {
+	// HalfLife is the age at which a post's score is halved
+	HalfLife time.Duration
+	// Recency is the score of a fresh post without any other signal
+	Recency float64
+	// Comments, Reactions and Reposts weigh the engagement of a post, whose
+	// logarithm is added to its score
+	Comments  float64
+	Reactions float64
+	Reposts   float64
+	// AuthorAffinity weighs the logarithm of the viewer's past interactions
+	// with the author
+	AuthorAffinity float64
+	// TagAffinity weighs each tag of the post the viewer follows
+	TagAffinity float64
+}
+
+// Candidate is a post that may show up in the ranked feed, along with the
+// signals it's scored from.
+type Candidate struct {
+	PostID     int64
+	ActivityAt time.Time
+	Comments   int64
+	Reactions  int64
+	Reposts    int64
+	// AuthorInteractions counts the comments, reposts and bookmarks of the
+	// viewer on posts of the author, and the viewer's reactions to the
+	// author's comments
+	AuthorInteractions int64
+	// FollowedTags counts the tags of the post the viewer follows
+	FollowedTags int64
+}
+
+// Score returns the score of the candidate at the given time: the sum of the
+// weighted signals, decayed by the age of the candidate's latest activity.
+func Score(c Candidate, w Weights, now time.Time) float64 {
+	decay := 1.0
+
+	if w.HalfLife > 0 {
+		age := max(now.Sub(c.ActivityAt), 0)
+		decay = math.Pow(0.5, age.Seconds()/w.HalfLife.Seconds())
+	}
+
+	engagement := max(
+		w.Comments*float64(c.Comments)+w.Reactions*float64(c.Reactions)+w.Reposts*float64(c.Reposts),
+		0,
+	)
+
+	score := w.Recency +
+		math.Log1p(engagement) +
+		w.AuthorAffinity*math.Log1p(float64(c.AuthorInteractions)) +
+		w.TagAffinity*float64(c.FollowedTags)
+
+	return decay * score
+}
+
+// Rank returns the candidates sorted by score at the given time, highest
+// first. Equal scores are ordered by latest activity then post ID, newest
+// first, so the same candidates always rank the same way.
+func Rank(candidates []Candidate, w Weights, now time.Time) []Candidate {
+	scores := make(map[int64]float64, len(candidates))
+	for _, c := range candidates {
+		scores[c.PostID] = Score(c, w, now)
+	}
+
+	ranked := slices.Clone(candidates)
+
+	slices.SortFunc(ranked, func(a Candidate, b Candidate) int {
+		return cmp.Or(
+			cmp.Compare(scores[b.PostID], scores[a.PostID]),
+			b.ActivityAt.Compare(a.ActivityAt),
+			cmp.Compare(b.PostID, a.PostID),
+		)
+	})
+
+	return ranked
+}
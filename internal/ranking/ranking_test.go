@@ -0,0 +1,142 @@
+package ranking
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func assertScore(t *testing.T, got float64, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("Score() = %v, want %v", got, want)
+	}
+}
+
+func TestScoreRecencyDecay(t *testing.T) {
+	w := Weights{HalfLife: 6 * time.Hour, Recency: 2}
+
+	tests := []struct {
+		name string
+		age  time.Duration
+		want float64
+	}{
+		{"fresh", 0, 2},
+		{"one half-life", 6 * time.Hour, 1},
+		{"two half-lives", 12 * time.Hour, 0.5},
+		{"half a half-life", 3 * time.Hour, 2 / math.Sqrt2},
+		{"in the future", -time.Hour, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertScore(t, Score(Candidate{ActivityAt: now.Add(-tt.age)}, w, now), tt.want)
+		})
+	}
+
+	t.Run("no half-life", func(t *testing.T) {
+		w := Weights{Recency: 2}
+		assertScore(t, Score(Candidate{ActivityAt: now.Add(-1000 * time.Hour)}, w, now), 2)
+	})
+}
+
+func TestScoreWeights(t *testing.T) {
+	c := Candidate{
+		ActivityAt:         now,
+		Comments:           3,
+		Reactions:          5,
+		Reposts:            2,
+		AuthorInteractions: 4,
+		FollowedTags:       3,
+	}
+
+	tests := []struct {
+		name string
+		w    Weights
+		want float64
+	}{
+		{"none", Weights{}, 0},
+		{"recency", Weights{Recency: 1.5}, 1.5},
+		{"comments", Weights{Comments: 2}, math.Log1p(6)},
+		{"reactions", Weights{Reactions: 0.5}, math.Log1p(2.5)},
+		{"reposts", Weights{Reposts: 3}, math.Log1p(6)},
+		{"engagement", Weights{Comments: 2, Reactions: 0.5, Reposts: 3}, math.Log1p(6 + 2.5 + 6)},
+		{"negative engagement", Weights{Comments: -10}, 0},
+		{"author affinity", Weights{AuthorAffinity: 2}, 2 * math.Log1p(4)},
+		{"tag affinity", Weights{TagAffinity: 0.5}, 1.5},
+		{"all", Weights{Recency: 1, Comments: 1, AuthorAffinity: 1, TagAffinity: 1}, 1 + math.Log1p(3) + math.Log1p(4) + 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertScore(t, Score(c, tt.w, now), tt.want)
+		})
+	}
+
+	t.Run("decay applies to every signal", func(t *testing.T) {
+		w := Weights{HalfLife: time.Hour, Recency: 1, Comments: 1, AuthorAffinity: 1, TagAffinity: 1}
+		old := c
+		old.ActivityAt = now.Add(-time.Hour)
+
+		assertScore(t, Score(old, w, now), Score(c, w, now)/2)
+	})
+}
+
+func TestRank(t *testing.T) {
+	w := Weights{HalfLife: 24 * time.Hour, Recency: 1, Comments: 1}
+
+	candidates := []Candidate{
+		{PostID: 1, ActivityAt: now.Add(-2 * time.Hour)},
+		{PostID: 2, ActivityAt: now.Add(-time.Hour)},
+		{PostID: 3, ActivityAt: now.Add(-3 * time.Hour), Comments: 50},
+		// same score and time as 2, so ordered by ID
+		{PostID: 4, ActivityAt: now.Add(-time.Hour)},
+		{PostID: 5, ActivityAt: now},
+	}
+
+	ranked := Rank(candidates, w, now)
+
+	got := make([]int64, len(ranked))
+	for i, c := range ranked {
+		got[i] = c.PostID
+	}
+
+	if want := []int64{3, 5, 4, 2, 1}; !slices.Equal(got, want) {
+		t.Errorf("Rank() = %v, want %v", got, want)
+	}
+
+	if candidates[0].PostID != 1 || candidates[4].PostID != 5 {
+		t.Error("Rank() reordered its input")
+	}
+}
+
+func TestRankTies(t *testing.T) {
+	// without a half-life, scores don't depend on time at all
+	w := Weights{Recency: 1}
+
+	candidates := []Candidate{
+		{PostID: 7, ActivityAt: now.Add(-time.Hour)},
+		{PostID: 3, ActivityAt: now},
+		{PostID: 9, ActivityAt: now.Add(-time.Hour)},
+		{PostID: 8, ActivityAt: now},
+	}
+
+	for range 3 {
+		ranked := Rank(candidates, w, now)
+
+		got := make([]int64, len(ranked))
+		for i, c := range ranked {
+			got[i] = c.PostID
+		}
+
+		if want := []int64{8, 3, 9, 7}; !slices.Equal(got, want) {
+			t.Fatalf("Rank() = %v, want %v", got, want)
+		}
+
+		slices.Reverse(candidates)
+	}
+}
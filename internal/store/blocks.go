@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrBlocked = errors.New("user is blocked")
+
+// BlockStore keeps the users each user blocked. Blocks work both ways: two
+// users with a block between them don't see each other's posts and can't
+// follow each other.
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block blocks a user, removing the follows between the two users.
+func (s *BlockStore) Block(ctx context.Context, userID int64, blockedID int64) error {
+	query := `
+		WITH blocked AS (
+			INSERT INTO user_blocks (user_id, blocked_id)
+			VALUES ($1, $2)
+			RETURNING user_id, blocked_id
+		),
+		unfollowed AS (
+			DELETE FROM followers f
+			USING blocked b
+			WHERE (f.user_id = b.user_id AND f.follower_id = b.blocked_id) OR
+				(f.user_id = b.blocked_id AND f.follower_id = b.user_id)
+		)
+		SELECT COUNT(*) FROM blocked
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, blockedID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505": // conflict error
+				return ErrConflict
+			case "23503": // foreign key violation error
+				return ErrNotFound
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, userID int64, blockedID int64) error {
+	query := `
+		DELETE FROM user_blocks
+		WHERE user_id = $1 AND blocked_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
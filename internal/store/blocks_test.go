@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		execErr error
+		want    error
+	}{
+		{"blocked", nil, nil},
+		{"already blocked", &pq.Error{Code: "23505"}, ErrConflict},
+		{"unknown user", &pq.Error{Code: "23503"}, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			// the follows both ways go in the same statement as the block
+			exec := mock.ExpectExec(`INSERT INTO user_blocks(.|\n)*DELETE FROM followers`).WithArgs(1, 2)
+			if tt.execErr != nil {
+				exec.WillReturnError(tt.execErr)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			err = (&BlockStore{db}).Block(context.Background(), 1, 2)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Block() error = %v, want %v", err, tt.want)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUnblock(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     error
+	}{
+		{"unblocked", 1, nil},
+		{"not blocked", 0, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec(`DELETE FROM user_blocks`).
+				WithArgs(1, 2).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			err = (&BlockStore{db}).Unblock(context.Background(), 1, 2)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Unblock() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowBlocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		execErr  error
+		want     error
+	}{
+		{"followed", 1, nil, nil},
+		{"block between the users", 0, nil, ErrBlocked},
+		{"already followed", 0, &pq.Error{Code: "23505"}, ErrConflict},
+		{"unknown user", 0, &pq.Error{Code: "23503"}, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			exec := mock.ExpectExec(`INSERT INTO followers(.|\n)*NOT EXISTS(.|\n)*user_blocks`).WithArgs(2, 1)
+			if tt.execErr != nil {
+				exec.WillReturnError(tt.execErr)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			}
+
+			err = (&FollowersStore{db}).Follow(context.Background(), 1, 2)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Follow() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostVisibleToChecksBlocksBothWays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// blocked either way, the post reads as missing
+	mock.ExpectQuery(`FROM user_blocks ub\s+WHERE \(ub.user_id = \$2 AND ub.blocked_id = p.user_id\) OR\s+\(ub.user_id = p.user_id AND ub.blocked_id = \$2\)`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	_, err = (&PostStore{db}).GetByID(context.Background(), 10, 1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByID() error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
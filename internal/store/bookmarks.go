@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Bookmark struct {
+	UserID    int64  `json:"-"`
+	PostID    int64  `json:"post_id"`
+	FolderID  *int64 `json:"folder_id"`
+	CreatedAt string `json:"created_at"`
+	Post      Post   `json:"post"`
+}
+
+type BookmarkFolder struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Create bookmarks a post, or moves an existing bookmark to another folder.
+// The folder, if any, has to belong to the bookmark's user.
+func (s *BookmarkStore) Create(ctx context.Context, bookmark *Bookmark) error {
+	query := `
+		INSERT INTO bookmarks (user_id, post_id, folder_id)
+		SELECT $1, $2, $3
+		WHERE $3::BIGINT IS NULL OR EXISTS (
+			SELECT 1 FROM bookmark_folders WHERE id = $3 AND user_id = $1
+		)
+		ON CONFLICT (user_id, post_id) DO UPDATE SET folder_id = EXCLUDED.folder_id
+		RETURNING created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, bookmark.UserID, bookmark.PostID, bookmark.FolderID).
+		Scan(&bookmark.CreatedAt)
+
+	if err != nil {
+		pqErr, ok := err.(*pq.Error)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows): // folder not found
+			return ErrNotFound
+		case ok && pqErr.Code == "23503": // foreign key violation error
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) Delete(ctx context.Context, userID int64, postID int64) error {
+	query := `
+		DELETE FROM bookmarks
+		WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rowsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsDeleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByUserID lists the user's bookmarks, newest first, along with the cursor
+// of the next page (nil on the last page). Bookmarked posts that were deleted
+// or are no longer visible to the user are skipped.
+func (s *BookmarkStore) GetByUserID(ctx context.Context, userID int64, bq PaginatedBookmarksQuery) ([]Bookmark, *Cursor, error) {
+	query := `
+		SELECT b.post_id, b.folder_id, b.created_at,
+			p.id, p.content, p.content_format, COALESCE(p.content_html, ''), p.title, p.tags, p.user_id, p.created_at, p.updated_at, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, p.quoted_post_id, p.in_reply_to_post_id,
+			u.username
+		FROM bookmarks b
+		INNER JOIN posts p ON p.id = b.post_id
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE b.user_id = $1 AND
+			p.deleted_at IS NULL AND
+			` + postVisibleTo("$1") + ` AND
+			($2::BIGINT IS NULL OR b.folder_id = $2) AND
+			($3::TIMESTAMPTZ IS NULL OR (b.created_at, b.post_id) < ($3, $4))
+		ORDER BY b.created_at DESC, b.post_id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		afterCreatedAt *time.Time
+		afterID        int64
+	)
+
+	if bq.Cursor != nil {
+		afterCreatedAt = &bq.Cursor.CreatedAt
+		afterID = bq.Cursor.ID
+	}
+
+	// fetching one extra row tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, bq.FolderID, afterCreatedAt, afterID, bq.Limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	createdAts := []time.Time{}
+
+	for rows.Next() {
+		var (
+			bookmark  Bookmark
+			createdAt time.Time
+		)
+
+		err := rows.Scan(
+			&bookmark.PostID, &bookmark.FolderID, &createdAt,
+			&bookmark.Post.ID, &bookmark.Post.Content, &bookmark.Post.ContentFormat, &bookmark.Post.ContentHTML,
+			&bookmark.Post.Title, pq.Array(&bookmark.Post.Tags),
+			&bookmark.Post.UserID, &bookmark.Post.CreatedAt, &bookmark.Post.UpdatedAt,
+			&bookmark.Post.Visibility, &bookmark.Post.ContentWarning, &bookmark.Post.Sensitive, &bookmark.Post.ContentWarningForced, &bookmark.Post.CommentPolicy, &bookmark.Post.QuotedPostID, &bookmark.Post.InReplyToPostID,
+			&bookmark.Post.User.Username,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		bookmark.UserID = userID
+		bookmark.CreatedAt = createdAt.Format(time.RFC3339)
+		bookmark.Post.User.ID = bookmark.Post.UserID
+		bookmark.Post.BookmarkedByMe = true
+
+		bookmarks = append(bookmarks, bookmark)
+		createdAts = append(createdAts, createdAt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if len(bookmarks) > bq.Limit {
+		bookmarks = bookmarks[:bq.Limit]
+		last := bookmarks[len(bookmarks)-1]
+		next = &Cursor{CreatedAt: createdAts[len(bookmarks)-1], ID: last.PostID}
+	}
+
+	posts := make([]*Post, len(bookmarks))
+	for i := range bookmarks {
+		posts[i] = &bookmarks[i].Post
+	}
+
+	postStore := &PostStore{s.db}
+	if err := postStore.hydrate(ctx, posts, userID); err != nil {
+		return nil, nil, err
+	}
+
+	return bookmarks, next, nil
+}
+
+func (s *BookmarkStore) CreateFolder(ctx context.Context, folder *BookmarkFolder) error {
+	query := `
+		INSERT INTO bookmark_folders (user_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, folder.UserID, folder.Name).Scan(&folder.ID, &folder.CreatedAt)
+
+	if err != nil {
+		pqErr, ok := err.(*pq.Error)
+
+		if ok && pqErr.Code == "23505" { // conflict error
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) GetFolders(ctx context.Context, userID int64) ([]BookmarkFolder, error) {
+	query := `
+		SELECT id, user_id, name, created_at
+		FROM bookmark_folders
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	folders := []BookmarkFolder{}
+
+	for rows.Next() {
+		var folder BookmarkFolder
+
+		if err := rows.Scan(&folder.ID, &folder.UserID, &folder.Name, &folder.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		folders = append(folders, folder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+// DeleteFolder removes one of the user's folders. Its bookmarks are kept,
+// outside of any folder.
+func (s *BookmarkStore) DeleteFolder(ctx context.Context, userID int64, folderID int64) error {
+	query := `
+		DELETE FROM bookmark_folders
+		WHERE id = $1 AND user_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, folderID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsDeleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type ExploreStore struct {
+	rdb *redis.Client
+}
+
+const (
+	// PopularPostsExpTime is how long a ranking of popular posts is handed
+	// out to first pages before it is computed again.
+	PopularPostsExpTime = time.Minute
+	// PopularRankingExpTime is how long a ranking is kept for the later
+	// pages of it.
+	PopularRankingExpTime = time.Minute * 15
+)
+
+// GetPopularRankedAt returns the time the current ranking of popular posts
+// over the last hours was computed at, nil if there is none.
+func (e *ExploreStore) GetPopularRankedAt(ctx context.Context, hours int) (*time.Time, error) {
+	cachedKey := fmt.Sprintf("popular-posts-%v", hours)
+
+	unix, err := e.rdb.Get(ctx, cachedKey).Int64()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	rankedAt := time.Unix(unix, 0)
+
+	return &rankedAt, nil
+}
+
+// SetPopularRankedAt makes the ranking computed at rankedAt the current one
+// for the last hours.
+func (e *ExploreStore) SetPopularRankedAt(ctx context.Context, hours int, rankedAt time.Time) error {
+	cachedKey := fmt.Sprintf("popular-posts-%v", hours)
+
+	return e.rdb.SetEX(ctx, cachedKey, rankedAt.Unix(), PopularPostsExpTime).Err()
+}
+
+// GetPopular returns the IDs of the ranking of popular posts over the last
+// hours computed at rankedAt, nil if it isn't cached.
+func (e *ExploreStore) GetPopular(ctx context.Context, hours int, rankedAt time.Time) ([]int64, error) {
+	cachedKey := fmt.Sprintf("popular-posts-%v-%v", hours, rankedAt.Unix())
+
+	data, err := e.rdb.Get(ctx, cachedKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	if err := json.Unmarshal([]byte(data), &ids); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (e *ExploreStore) SetPopular(ctx context.Context, hours int, rankedAt time.Time, ids []int64) error {
+	cachedKey := fmt.Sprintf("popular-posts-%v-%v", hours, rankedAt.Unix())
+
+	json, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	return e.rdb.SetEX(ctx, cachedKey, json, PopularRankingExpTime).Err()
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/tiskae/go-social/internal/store"
@@ -12,10 +13,31 @@ type Storage struct {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
 	}
+	Tags interface {
+		GetTrending(context.Context, string) ([]store.TrendingTag, error)
+		SetTrending(context.Context, string, []store.TrendingTag) error
+	}
+	Explore interface {
+		GetPopularRankedAt(context.Context, int) (*time.Time, error)
+		SetPopularRankedAt(context.Context, int, time.Time) error
+		GetPopular(context.Context, int, time.Time) ([]int64, error)
+		SetPopular(context.Context, int, time.Time, []int64) error
+	}
+	Timelines interface {
+		Exists(context.Context, int64) (bool, error)
+		Seed(context.Context, int64, []store.TimelineEntry) error
+		Add(context.Context, []int64, ...store.TimelineEntry) error
+		Remove(context.Context, []int64, ...int64) error
+		Range(context.Context, int64, store.TimelineRange) ([]store.TimelineEntry, error)
+		TruncatedAt(context.Context, int64) (*time.Time, error)
+	}
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:     &UserStore{rdb: rdb},
+		Tags:      &TagStore{rdb: rdb},
+		Explore:   &ExploreStore{rdb: rdb},
+		Timelines: &TimelineStore{rdb: rdb},
 	}
 }
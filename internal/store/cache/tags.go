@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+type TagStore struct {
+	rdb *redis.Client
+}
+
+const TrendingTagsExpTime = time.Minute * 5
+
+func (t *TagStore) GetTrending(ctx context.Context, window string) ([]store.TrendingTag, error) {
+	cachedKey := fmt.Sprintf("trending-tags-%v", window)
+
+	data, err := t.rdb.Get(ctx, cachedKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	tags := []store.TrendingTag{}
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (t *TagStore) SetTrending(ctx context.Context, window string, tags []store.TrendingTag) error {
+	cachedKey := fmt.Sprintf("trending-tags-%v", window)
+
+	json, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	return t.rdb.SetEX(ctx, cachedKey, json, TrendingTagsExpTime).Err()
+}
@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/tiskae/go-social/internal/store"
+)
+
+// TimelineStore keeps home timelines as sorted sets of post IDs scored by
+// activity time in microseconds. IDs are zero padded so that entries with the
+// same time sort by ID, as in the database.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+// TimelineMaxLength caps how many entries a timeline keeps, the oldest ones
+// being dropped first.
+const TimelineMaxLength = 800
+
+// TimelineExpTime is how long the timeline of a user who stopped reading it
+// is kept up to date.
+const TimelineExpTime = time.Hour * 24 * 7
+
+// timelineFanOutBatchSize bounds how many timelines one call to Redis writes
+// to.
+const timelineFanOutBatchSize = 500
+
+// addToExistingTimelines adds the entries given as score and member pairs
+// after the max length in ARGV to the timelines in KEYS that exist, leaving
+// alone the ones that expired or were never built.
+var addToExistingTimelines = redis.NewScript(`
+	for _, key in ipairs(KEYS) do
+		if redis.call('EXISTS', key) == 1 then
+			for i = 2, #ARGV, 2 do
+				redis.call('ZADD', key, ARGV[i], ARGV[i + 1])
+			end
+			redis.call('ZREMRANGEBYRANK', key, 0, -tonumber(ARGV[1]) - 1)
+		end
+	end
+	return 0
+`)
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%v", userID)
+}
+
+func timelineMember(postID int64) string {
+	return fmt.Sprintf("%019d", postID)
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, -1):
+		return "-inf"
+	case math.IsInf(score, 1):
+		return "+inf"
+	default:
+		return strconv.FormatFloat(score, 'f', -1, 64)
+	}
+}
+
+func (t *TimelineStore) Exists(ctx context.Context, userID int64) (bool, error) {
+	n, err := t.rdb.Exists(ctx, timelineKey(userID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// Seed replaces the user's timeline with the given entries.
+func (t *TimelineStore) Seed(ctx context.Context, userID int64, entries []store.TimelineEntry) error {
+	key := timelineKey(userID)
+
+	members := make([]*redis.Z, len(entries))
+	for i, entry := range entries {
+		members[i] = &redis.Z{Score: timelineScore(entry.ActivityAt), Member: timelineMember(entry.PostID)}
+	}
+
+	_, err := t.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, key, members...)
+			pipe.ZRemRangeByRank(ctx, key, 0, -TimelineMaxLength-1)
+			pipe.Expire(ctx, key, TimelineExpTime)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Add adds the entries to the timelines of the users, moving entries of posts
+// already there to their new activity time. Timelines that don't exist are
+// left to be seeded on their next read.
+func (t *TimelineStore) Add(ctx context.Context, userIDs []int64, entries ...store.TimelineEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	args := []any{TimelineMaxLength}
+	for _, entry := range entries {
+		args = append(args, formatScore(timelineScore(entry.ActivityAt)), timelineMember(entry.PostID))
+	}
+
+	for batch := range slices.Chunk(userIDs, timelineFanOutBatchSize) {
+		keys := make([]string, len(batch))
+		for i, userID := range batch {
+			keys[i] = timelineKey(userID)
+		}
+
+		if err := addToExistingTimelines.Run(ctx, t.rdb, keys, args...).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes the posts from the timelines of the users.
+func (t *TimelineStore) Remove(ctx context.Context, userIDs []int64, postIDs ...int64) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(postIDs))
+	for i, postID := range postIDs {
+		members[i] = timelineMember(postID)
+	}
+
+	for batch := range slices.Chunk(userIDs, timelineFanOutBatchSize) {
+		_, err := t.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, userID := range batch {
+				pipe.ZRem(ctx, timelineKey(userID), members...)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Range returns up to tr.Limit entries of the user's timeline, ordered by
+// activity time then post ID, and keeps the timeline from expiring.
+func (t *TimelineStore) Range(ctx context.Context, userID int64, tr store.TimelineRange) ([]store.TimelineEntry, error) {
+	key := timelineKey(userID)
+
+	low, high := math.Inf(-1), math.Inf(1)
+
+	if tr.Since != nil {
+		low = timelineScore(*tr.Since)
+	}
+
+	if tr.Until != nil {
+		high = timelineScore(*tr.Until)
+	}
+
+	count := int64(tr.Limit)
+
+	// entries at the cursor's time are fetched too and skipped up to the
+	// cursor's post, as scores can't tell them apart
+	if tr.After != nil {
+		after := timelineScore(tr.After.CreatedAt)
+
+		if tr.Desc {
+			high = math.Min(high, after)
+		} else {
+			low = math.Max(low, after)
+		}
+
+		ties, err := t.rdb.ZCount(ctx, key, formatScore(after), formatScore(after)).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		count += ties
+	}
+
+	if low > high {
+		return []store.TimelineEntry{}, nil
+	}
+
+	by := &redis.ZRangeBy{Min: formatScore(low), Max: formatScore(high), Count: count}
+
+	var (
+		members []redis.Z
+		err     error
+	)
+
+	if tr.Desc {
+		members, err = t.rdb.ZRevRangeByScoreWithScores(ctx, key, by).Result()
+	} else {
+		members, err = t.rdb.ZRangeByScoreWithScores(ctx, key, by).Result()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.rdb.Expire(ctx, key, TimelineExpTime).Err(); err != nil {
+		return nil, err
+	}
+
+	entries := []store.TimelineEntry{}
+
+	for _, member := range members {
+		postID, err := strconv.ParseInt(member.Member.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		if tr.After != nil && member.Score == timelineScore(tr.After.CreatedAt) &&
+			((tr.Desc && postID >= tr.After.ID) || (!tr.Desc && postID <= tr.After.ID)) {
+			continue
+		}
+
+		entries = append(entries, store.TimelineEntry{PostID: postID, ActivityAt: time.UnixMicro(int64(member.Score))})
+
+		if len(entries) == tr.Limit {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// TruncatedAt returns the activity time of the oldest entry of the user's
+// timeline when the timeline is full, as entries older than it may have been
+// dropped, and nil otherwise.
+func (t *TimelineStore) TruncatedAt(ctx context.Context, userID int64) (*time.Time, error) {
+	key := timelineKey(userID)
+
+	var (
+		card   *redis.IntCmd
+		oldest *redis.ZSliceCmd
+	)
+
+	_, err := t.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		card = pipe.ZCard(ctx, key)
+		oldest = pipe.ZRangeWithScores(ctx, key, 0, 0)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if card.Val() < TimelineMaxLength || len(oldest.Val()) == 0 {
+		return nil, nil
+	}
+
+	truncatedAt := time.UnixMicro(int64(oldest.Val()[0].Score))
+
+	return &truncatedAt, nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type CommentReactionStore struct {
+	db *sql.DB
+}
+
+// Set reacts to a comment with emoji, replacing the user's previous reaction
+// to it. Reacting to a tombstone is reported as ErrNotFound.
+func (s *CommentReactionStore) Set(ctx context.Context, commentID int64, userID int64, emoji string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var deleted bool
+
+		query := `SELECT deleted_at IS NOT NULL FROM comments WHERE id = $1 FOR SHARE`
+		if err := tx.QueryRowContext(ctx, query, commentID).Scan(&deleted); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if deleted {
+			return ErrNotFound
+		}
+
+		// xmax is only set on rows updated by the upsert
+		query = `
+			INSERT INTO comment_reactions (comment_id, user_id, emoji)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (comment_id, user_id) WHERE deleted_at IS NULL DO UPDATE SET emoji = EXCLUDED.emoji
+			RETURNING xmax = 0
+		`
+
+		var inserted bool
+
+		if err := tx.QueryRowContext(ctx, query, commentID, userID, emoji).Scan(&inserted); err != nil {
+			pqErr, ok := err.(*pq.Error)
+
+			switch {
+			case ok && pqErr.Code == "23503": // foreign key violation error
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if !inserted {
+			return nil
+		}
+
+		query = `UPDATE comments SET reactions_count = reactions_count + 1 WHERE id = $1`
+		_, err := tx.ExecContext(ctx, query, commentID)
+
+		return err
+	})
+}
+
+// Delete removes the user's reaction to a comment. The reaction is kept with
+// deleted_at set, so that comments can still be ranked as of before.
+func (s *CommentReactionStore) Delete(ctx context.Context, commentID int64, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			UPDATE comment_reactions
+			SET deleted_at = NOW()
+			WHERE comment_id = $1 AND user_id = $2 AND deleted_at IS NULL
+		`
+
+		result, err := tx.ExecContext(ctx, query, commentID, userID)
+		if err != nil {
+			return err
+		}
+
+		rowsDeleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		// no reaction found, hence nothing got deleted
+		if rowsDeleted == 0 {
+			return ErrNotFound
+		}
+
+		query = `UPDATE comments SET reactions_count = reactions_count - 1 WHERE id = $1`
+		_, err = tx.ExecContext(ctx, query, commentID)
+
+		return err
+	})
+}
@@ -3,6 +3,10 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type Comment struct {
@@ -11,73 +15,459 @@ type Comment struct {
 	UserID    int64  `json:"user_id"`
 	Content   string `json:"content"`
 	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	// EditedAt is set once the comment was edited.
+	EditedAt *string   `json:"edited_at,omitempty"`
+	User     User      `json:"user"`
+	Mentions []Mention `json:"mentions"`
+	// ParentCommentID is set on replies to another comment.
+	ParentCommentID *int64 `json:"parent_comment_id,omitempty"`
+	// Deleted comments that have replies are kept as tombstones, without
+	// content nor author, so that their replies stay in place.
+	Deleted bool `json:"deleted"`
+	// Depth is 1 for the comments listed, and grows down their replies.
+	Depth int `json:"depth"`
+	// RepliesCount is the number of direct replies, which can be more than
+	// the Replies listed: the others are loaded with GetReplies.
+	RepliesCount int        `json:"replies_count"`
+	Replies      []*Comment `json:"replies"`
+	// ReactionsCount is the number of users who reacted to the comment.
+	ReactionsCount int `json:"reactions_count"`
+}
+
+const (
+	CommentSortOldest      = "oldest"
+	CommentSortNewest      = "newest"
+	CommentSortMostReacted = "most_reacted"
+)
+
+// commentSorts maps the sort orders of top-level comments to their ORDER BY
+// clause and the keyset condition matching it, for comments aliased as c.
+// The cursor's created_at and id are bound to $3 and $4. Sorts by count read
+// it from r.count, joined in from their join clause as of $8, with the
+// cursor's count bound to $9.
+var commentSorts = map[string]struct {
+	orderBy, after, join string
+	byCount              bool
+}{
+	CommentSortOldest: {
+		orderBy: `c.created_at, c.id`,
+		after:   `(c.created_at, c.id) > ($3, $4)`,
+	},
+	CommentSortNewest: {
+		orderBy: `c.created_at DESC, c.id DESC`,
+		after:   `(c.created_at, c.id) < ($3, $4)`,
+	},
+	// reactions_count keeps changing while the comments are paged through,
+	// so the ranking counts the reactions there were at the time of the
+	// first page instead
+	CommentSortMostReacted: {
+		orderBy: `r.count DESC, c.created_at DESC, c.id DESC`,
+		after:   `(r.count, c.created_at, c.id) < ($9, $3, $4)`,
+		join: `CROSS JOIN LATERAL (
+					SELECT COUNT(*) AS count
+					FROM comment_reactions cr
+					WHERE cr.comment_id = c.id AND
+						cr.created_at <= $8::TIMESTAMPTZ AND
+						(cr.deleted_at IS NULL OR cr.deleted_at > $8::TIMESTAMPTZ)
+				) r`,
+		byCount: true,
+	},
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
+// Create adds a comment along with its mentions, or a reply when
+// ParentCommentID is set. Replying to a comment that was deleted meanwhile is
+// reported as ErrNotFound.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-		INSERT INTO comments (post_id, user_id, content)
-		VALUES ($1, $2, $3)
+		INSERT INTO comments (post_id, user_id, content, parent_comment_id)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content).
-		Scan(&comment.ID, &comment.CreatedAt)
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content, comment.ParentCommentID).
+			Scan(&comment.ID, &comment.CreatedAt)
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			pqErr, ok := err.(*pq.Error)
 
-	return nil
+			switch {
+			case ok && pqErr.Code == "23503": // foreign key violation error
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		comment.Depth = 1
+		comment.Replies = []*Comment{}
+
+		return setCommentMentions(ctx, tx, comment.PostID, comment.ID, comment.Mentions)
+	})
+}
+
+// GetByPostID lists the top-level comments of a post in the cq.Sort order,
+// each with a tree of its replies cq.Depth levels down, along with the
+// cursor of the next page (nil on the last page).
+func (s *CommentStore) GetByPostID(ctx context.Context, postID int64, cq CommentsQuery) ([]*Comment, *Cursor, error) {
+	return s.getTree(ctx, postID, nil, cq)
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetReplies lists the replies to a comment, oldest first, each with a tree of
+// its own replies, along with the cursor of the next page (nil on the last
+// page).
+func (s *CommentStore) GetReplies(ctx context.Context, postID int64, commentID int64, cq CommentsQuery) ([]*Comment, *Cursor, error) {
+	// replies always read as a conversation
+	cq.Sort = CommentSortOldest
+
+	return s.getTree(ctx, postID, &commentID, cq)
+}
+
+// getTree walks down the comments of a post from the replies to parentID, or
+// from the top-level comments when parentID is nil. The first level is paged
+// through in the cq.Sort order, while each comment below lists at most
+// cq.RepliesLimit replies, oldest first.
+func (s *CommentStore) getTree(ctx context.Context, postID int64, parentID *int64, cq CommentsQuery) ([]*Comment, *Cursor, error) {
+	sort, ok := commentSorts[cq.Sort]
+	if !ok {
+		sort = commentSorts[CommentSortOldest]
+	}
+
+	count := "0::BIGINT"
+	if sort.byCount {
+		count = "r.count"
+	}
+
 	query := `
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id
-		FROM comments c
-		INNER JOIN users u ON c.user_id = u.id
-		WHERE c.post_id = $1
-		ORDER BY c.created_at
+		WITH RECURSIVE tree AS (
+			SELECT roots.id, 1 AS depth, roots.position, roots.count
+			FROM (
+				SELECT c.id, ROW_NUMBER() OVER (ORDER BY ` + sort.orderBy + `) AS position, ` + count + ` AS count
+				FROM comments c
+				` + sort.join + `
+				WHERE c.post_id = $1 AND
+					c.parent_comment_id IS NOT DISTINCT FROM $2 AND
+					($3::TIMESTAMPTZ IS NULL OR ` + sort.after + `)
+				ORDER BY ` + sort.orderBy + `
+				LIMIT $5
+			) roots
+			UNION ALL
+			SELECT r.id, t.depth + 1, 0::BIGINT, 0::BIGINT
+			FROM tree t
+			CROSS JOIN LATERAL (
+				SELECT c.id
+				FROM comments c
+				WHERE c.parent_comment_id = t.id
+				ORDER BY c.created_at, c.id
+				LIMIT $7
+			) r
+			WHERE t.depth < $6
+		)
+		SELECT c.id, c.post_id, c.user_id, c.parent_comment_id, c.content, c.created_at, c.edited_at,
+			c.deleted_at IS NOT NULL, u.username,
+			(SELECT COUNT(*) FROM comments rc WHERE rc.parent_comment_id = c.id) replies_count,
+			c.reactions_count, t.depth, t.count
+		FROM tree t
+		INNER JOIN comments c ON c.id = t.id
+		INNER JOIN users u ON u.id = c.user_id
+		ORDER BY t.depth, t.position, c.created_at, c.id
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	comments := []Comment{}
+	var (
+		afterCreatedAt *time.Time
+		afterID        int64
+		afterCount     int64
+		hasMore        bool
+		// reactions are stored to the second, so the ones counted are those
+		// of the last whole second, which no later reaction can fall in
+		asOf = time.Now().Truncate(time.Second).Add(-time.Second)
+	)
+
+	if cq.Cursor != nil {
+		afterCreatedAt = &cq.Cursor.CreatedAt
+		afterID = cq.Cursor.ID
+		afterCount = cq.Cursor.Count
+
+		if cq.Cursor.AsOf != nil {
+			asOf = *cq.Cursor.AsOf
+		}
+	}
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	// fetching one extra comment tells whether there is a next page
+	args := []any{postID, parentID, afterCreatedAt, afterID, cq.Limit + 1, cq.Depth, cq.RepliesLimit}
+	if sort.byCount {
+		args = append(args, asOf, afterCount)
+	}
 
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer rows.Close()
 
+	comments := []*Comment{}
+	roots := []*Comment{}
+	createdAts := []time.Time{}
+	counts := []int64{}
+	// comments come ordered by depth, so parents are always seen first
+	nodes := map[int64]*Comment{}
+
 	for rows.Next() {
-		var comment Comment
-		if err := rows.Scan(
+		var (
+			comment   = &Comment{Replies: []*Comment{}}
+			createdAt time.Time
+			count     int64
+		)
+
+		err := rows.Scan(
 			&comment.ID,
 			&comment.PostID,
 			&comment.UserID,
+			&comment.ParentCommentID,
 			&comment.Content,
-			&comment.CreatedAt,
+			&createdAt,
+			&comment.EditedAt,
+			&comment.Deleted,
 			&comment.User.Username,
-			&comment.User.ID); err != nil {
-			return nil, err
+			&comment.RepliesCount,
+			&comment.ReactionsCount,
+			&comment.Depth,
+			&count)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		comment.CreatedAt = createdAt.Format(time.RFC3339)
+		comment.User.ID = comment.UserID
+		comment.hideIfDeleted()
+
+		if comment.Depth == 1 {
+			if len(roots) == cq.Limit {
+				// the extra comment is left out, along with its replies
+				hasMore = true
+				continue
+			}
+
+			roots = append(roots, comment)
+			createdAts = append(createdAts, createdAt)
+			counts = append(counts, count)
+		} else if parent, ok := nodes[*comment.ParentCommentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		} else {
+			continue
 		}
+
+		nodes[comment.ID] = comment
 		comments = append(comments, comment)
 	}
 
 	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if hasMore {
+		last := len(roots) - 1
+		next = &Cursor{CreatedAt: createdAts[last], ID: roots[last].ID}
+
+		if sort.byCount {
+			next.Count = counts[last]
+			next.AsOf = &asOf
+		}
+	}
+
+	if err := s.attachMentions(ctx, comments); err != nil {
+		return nil, nil, err
+	}
+
+	return roots, next, nil
+}
+
+// GetByID fetches a comment of the post, which may be a tombstone.
+func (s *CommentStore) GetByID(ctx context.Context, postID int64, commentID int64) (*Comment, error) {
+	query := `
+		SELECT c.id, c.post_id, c.user_id, c.parent_comment_id, c.content, c.created_at, c.edited_at,
+			c.deleted_at IS NOT NULL, u.username,
+			(SELECT COUNT(*) FROM comments rc WHERE rc.parent_comment_id = c.id) replies_count,
+			c.reactions_count
+		FROM comments c
+		INNER JOIN users u ON c.user_id = u.id
+		WHERE c.id = $1 AND c.post_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	comment := &Comment{Depth: 1, Replies: []*Comment{}}
+
+	err := s.db.QueryRowContext(ctx, query, commentID, postID).Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&comment.ParentCommentID,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.EditedAt,
+		&comment.Deleted,
+		&comment.User.Username,
+		&comment.RepliesCount,
+		&comment.ReactionsCount)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	comment.User.ID = comment.UserID
+	comment.hideIfDeleted()
+
+	if err := s.attachMentions(ctx, []*Comment{comment}); err != nil {
 		return nil, err
 	}
 
-	return comments, nil
+	return comment, nil
+}
+
+// Update saves the comment's content, marks it as edited and replaces its
+// mentions. Tombstones can't be edited and are reported as ErrNotFound.
+func (s *CommentStore) Update(ctx context.Context, comment *Comment) error {
+	query := `
+		UPDATE comments
+		SET content = $2, edited_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING content, edited_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, comment.ID, comment.Content).
+			Scan(&comment.Content, &comment.EditedAt)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return setCommentMentions(ctx, tx, comment.PostID, comment.ID, comment.Mentions)
+	})
+}
+
+// Delete deletes a comment along with its mentions. A comment with replies is
+// turned into a tombstone instead, and tombstones left without replies once
+// the comment is gone are deleted too.
+func (s *CommentStore) Delete(ctx context.Context, commentID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var (
+			parentID   *int64
+			hasReplies bool
+		)
+
+		query := `
+			SELECT c.parent_comment_id, EXISTS (SELECT 1 FROM comments r WHERE r.parent_comment_id = c.id)
+			FROM comments c
+			WHERE c.id = $1 AND c.deleted_at IS NULL
+			FOR UPDATE
+		`
+
+		if err := tx.QueryRowContext(ctx, query, commentID).Scan(&parentID, &hasReplies); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if hasReplies {
+			query = `UPDATE comments SET content = '', deleted_at = NOW() WHERE id = $1`
+			if _, err := tx.ExecContext(ctx, query, commentID); err != nil {
+				return err
+			}
+
+			query = `DELETE FROM mentions WHERE comment_id = $1`
+			_, err := tx.ExecContext(ctx, query, commentID)
+
+			return err
+		}
+
+		query = `DELETE FROM comments WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, commentID); err != nil {
+			return err
+		}
+
+		// walks up the tombstones that were only kept for this comment
+		query = `
+			DELETE FROM comments c
+			WHERE c.id = $1 AND c.deleted_at IS NOT NULL AND
+				NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_comment_id = c.id)
+			RETURNING c.parent_comment_id
+		`
+
+		for parentID != nil {
+			err := tx.QueryRowContext(ctx, query, *parentID).Scan(&parentID)
+
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return nil
+			case err != nil:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// hideIfDeleted blanks out the content and author of tombstones.
+func (c *Comment) hideIfDeleted() {
+	if c.Deleted {
+		c.Content = ""
+		c.UserID = 0
+		c.User = User{}
+		c.EditedAt = nil
+	}
+}
+
+func (s *CommentStore) attachMentions(ctx context.Context, comments []*Comment) error {
+	ids := make([]int64, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	mentioned, err := getMentionedUsers(ctx, s.db, ids, true)
+	if err != nil {
+		return err
+	}
+
+	for _, comment := range comments {
+		comment.Mentions = BuildMentions(comment.Content, mentioned[comment.ID])
+	}
+
+	return nil
 }
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// sameTime matches a time argument regardless of its location.
+type sameTime time.Time
+
+func (t sameTime) Match(v driver.Value) bool {
+	at, ok := v.(time.Time)
+	return ok && at.Equal(time.Time(t))
+}
+
+var commentRowColumns = []string{
+	"id", "post_id", "user_id", "parent_comment_id", "content", "created_at", "edited_at",
+	"deleted", "username", "replies_count", "reactions_count", "depth", "count",
+}
+
+// Comments sorted by most reactions are ranked by their reactions as of the
+// first page, which every next page keeps using, rather than by the live
+// reactions_count.
+func TestGetByPostIDMostReactedKeepsItsSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := &CommentStore{db}
+	cq := CommentsQuery{Limit: 1, Sort: CommentSortMostReacted, Depth: 1, RepliesLimit: 1}
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectQuery(`(?s)FROM comments c\s+CROSS JOIN LATERAL .*cr.created_at <= \$8::TIMESTAMPTZ.*ORDER BY r.count DESC`).
+		WithArgs(5, nil, nil, 0, 2, 1, 1, sqlmock.AnyArg(), 0).
+		WillReturnRows(sqlmock.NewRows(commentRowColumns).
+			AddRow(1, 5, 1, nil, "first", createdAt, nil, false, "author", 0, 9, 1, 4).
+			AddRow(2, 5, 1, nil, "second", createdAt, nil, false, "author", 0, 1, 1, 3))
+	mock.ExpectQuery(`FROM mentions`).WillReturnRows(sqlmock.NewRows([]string{"comment_id", "user_id", "username", "start", "end"}))
+
+	comments, next, err := s.GetByPostID(context.Background(), 5, cq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comments) != 1 || next == nil {
+		t.Fatalf("got %d comments and next cursor %v, want 1 and a cursor", len(comments), next)
+	}
+
+	// the cursor holds the count the comment was ranked with, not its
+	// current reactions_count
+	if next.Count != 4 || next.AsOf == nil {
+		t.Fatalf("next cursor = %+v, want count 4 and a snapshot time", next)
+	}
+
+	if next.AsOf.After(time.Now().Add(-time.Second)) {
+		t.Errorf("snapshot time %v is not in a past second", next.AsOf)
+	}
+
+	decoded, err := DecodeCursor(next.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cq.Cursor = &decoded
+
+	mock.ExpectQuery(`\(r.count, c.created_at, c.id\) < \(\$9, \$3, \$4\)`).
+		WithArgs(5, nil, createdAt, 1, 2, 1, 1, sameTime(*next.AsOf), 4).
+		WillReturnRows(sqlmock.NewRows(commentRowColumns))
+	mock.ExpectQuery(`FROM mentions`).WillReturnRows(sqlmock.NewRows([]string{"comment_id", "user_id", "username", "start", "end"}))
+
+	if _, _, err := s.GetByPostID(context.Background(), 5, cq); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list ordered by (created_at, id), used for
+// keyset pagination. Clients only ever see its opaque encoded form. Count is
+// set for lists ordered by a count first, such as (reactions_count,
+// created_at, id). Counts that change over time are taken as of AsOf, the
+// same for every page. Before is set on cursors of previous pages, for lists
+// that can be paged through both ways. Sort is the sort order of the list the
+// cursor was made for, for lists that can be sorted in ways whose cursors
+// don't mix.
+type Cursor struct {
+	CreatedAt time.Time  `json:"t"`
+	ID        int64      `json:"id"`
+	Count     int64      `json:"n,omitempty"`
+	AsOf      *time.Time `json:"at,omitempty"`
+	Before    bool       `json:"b,omitempty"`
+	Sort      string     `json:"s,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil || c.CreatedAt.IsZero() {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tiskae/go-social/internal/ranking"
+)
+
+// GetPublic lists the public posts of all users but the ones the viewer
+// blocked, muted or is blocked by, newest first, along with the cursor of the
+// next page (nil on the last page).
+func (s *PostStore) GetPublic(ctx context.Context, viewerID int64, eq ExploreQuery) ([]PostWithMetadata, *Cursor, error) {
+	query := `
+		SELECT ` + userPostColumns + `
+		FROM posts p
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.visibility = 'public' AND
+			p.status = 'published' AND
+			p.deleted_at IS NULL AND
+			` + postVisibleTo("$2") + ` AND
+			` + postNotMuted("$2") + ` AND
+			(p.title ILIKE '%' || $1 || '%' OR p.content ILIKE '%' || $1 || '%') AND
+			($6 = '{}' OR p.tags @> $6::TEXT[]) AND
+			($3::TIMESTAMPTZ IS NULL OR (p.created_at, p.id) < ($3, $4))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		afterCreatedAt *time.Time
+		afterID        int64
+	)
+
+	if eq.Cursor != nil {
+		afterCreatedAt = &eq.Cursor.CreatedAt
+		afterID = eq.Cursor.ID
+	}
+
+	// fetching one extra row tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, eq.Search, viewerID, afterCreatedAt, afterID, eq.Limit+1, pq.Array(eq.Tags))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	createdAts := []time.Time{}
+
+	for rows.Next() {
+		var (
+			post      PostWithMetadata
+			createdAt time.Time
+		)
+
+		if err := scanUserPost(rows, &post, &createdAt); err != nil {
+			return nil, nil, err
+		}
+
+		posts = append(posts, post)
+		createdAts = append(createdAts, createdAt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if len(posts) > eq.Limit {
+		posts = posts[:eq.Limit]
+		next = &Cursor{CreatedAt: createdAts[eq.Limit-1], ID: posts[eq.Limit-1].ID}
+	}
+
+	if err := s.hydrate(ctx, postPointers(posts), viewerID); err != nil {
+		return nil, nil, err
+	}
+
+	return posts, next, nil
+}
+
+// GetPopular returns the IDs of the public posts published between since and
+// rankedAt that got the most engagement, weighing their comments, the
+// reactions to those and their reposts. Posts nobody engaged with are left
+// out.
+//
+// Engagement is taken as of rankedAt, and posts deleted since still hold
+// their place, so that the same ranking comes out when it is computed again
+// for a later page. Comments and reactions removed since are still counted,
+// but undone reposts leave no trace.
+func (s *PostStore) GetPopular(ctx context.Context, rankedAt time.Time, since time.Time, w ranking.Weights, limit int) ([]int64, error) {
+	query := `
+		SELECT id
+		FROM (
+			SELECT
+				p.id, p.created_at,
+				$2::FLOAT8 * (SELECT COUNT(*)
+					FROM comments c
+					WHERE c.post_id = p.id AND
+						c.created_at <= $6 AND
+						(c.deleted_at IS NULL OR c.deleted_at > $6)) +
+				$3::FLOAT8 * (SELECT COUNT(*)
+					FROM comment_reactions cr
+					INNER JOIN comments c ON c.id = cr.comment_id
+					WHERE c.post_id = p.id AND
+						(c.deleted_at IS NULL OR c.deleted_at > $6) AND
+						cr.created_at <= $6 AND
+						(cr.deleted_at IS NULL OR cr.deleted_at > $6)) +
+				$4::FLOAT8 * (SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id AND r.created_at <= $6) AS engagement
+			FROM posts p
+			WHERE p.visibility = 'public' AND
+				p.status = 'published' AND
+				(p.deleted_at IS NULL OR p.deleted_at > $6) AND
+				p.created_at >= $1 AND
+				p.created_at <= $6
+		) scored
+		WHERE engagement > 0
+		ORDER BY engagement DESC, created_at DESC, id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, w.Comments, w.Reactions, w.Reposts, limit, rankedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetPublicByIDs loads the public posts with the given IDs in the order of
+// the IDs, leaving out the ones deleted or hidden since and the ones of users
+// the viewer blocked, muted or is blocked by.
+func (s *PostStore) GetPublicByIDs(ctx context.Context, postIDs []int64, viewerID int64) ([]PostWithMetadata, error) {
+	if len(postIDs) == 0 {
+		return []PostWithMetadata{}, nil
+	}
+
+	query := `
+		SELECT ` + userPostColumns + `
+		FROM UNNEST($1::BIGINT[]) WITH ORDINALITY AS t(post_id, position)
+		INNER JOIN posts p ON p.id = t.post_id
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.visibility = 'public' AND
+			p.status = 'published' AND
+			p.deleted_at IS NULL AND
+			` + postVisibleTo("$2") + ` AND
+			` + postNotMuted("$2") + `
+		ORDER BY t.position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+
+	for rows.Next() {
+		var (
+			post      PostWithMetadata
+			createdAt time.Time
+		)
+
+		if err := scanUserPost(rows, &post, &createdAt); err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.hydrate(ctx, postPointers(posts), viewerID); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
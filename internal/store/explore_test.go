@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tiskae/go-social/internal/ranking"
+)
+
+// Explore leaves out the users blocked either way and the users the viewer
+// muted, both when listing and when loading the cached popular posts.
+func TestGetPublicLeavesOutBlockedAndMutedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`(?s)FROM user_blocks ub.*ub.blocked_id = \$2.*um.user_id = \$2 AND um.muted_id = p.user_id`).
+		WillReturnError(errQueryChecked)
+
+	_, _, err = (&PostStore{db}).GetPublic(context.Background(), 1, ExploreQuery{Limit: 20, Tags: []string{}})
+	if !errors.Is(err, errQueryChecked) {
+		t.Fatalf("GetPublic() error = %v, want the query to be checked", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetPublicByIDsLeavesOutBlockedAndMutedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`(?s)FROM user_blocks ub.*ub.blocked_id = \$2.*um.user_id = \$2 AND um.muted_id = p.user_id`).
+		WillReturnError(errQueryChecked)
+
+	_, err = (&PostStore{db}).GetPublicByIDs(context.Background(), []int64{3, 4}, 1)
+	if !errors.Is(err, errQueryChecked) {
+		t.Fatalf("GetPublicByIDs() error = %v, want the query to be checked", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Popular posts are ranked from their engagement as of the ranking time,
+// bound to $6, and keep their place once deleted, so that a ranking computed
+// again for a later page comes out the same.
+func TestGetPopularAsOfRankedAt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rankedAt := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	since := rankedAt.Add(-24 * time.Hour)
+	w := ranking.Weights{Comments: 2, Reactions: 1, Reposts: 3}
+
+	mock.ExpectQuery(`(?s)`+
+		`c.created_at <= \$6 AND\s+\(c.deleted_at IS NULL OR c.deleted_at > \$6\)\).*`+
+		`cr.created_at <= \$6 AND\s+\(cr.deleted_at IS NULL OR cr.deleted_at > \$6\)\).*`+
+		`r.created_at <= \$6\) AS engagement.*`+
+		`\(p.deleted_at IS NULL OR p.deleted_at > \$6\) AND\s+p.created_at >= \$1 AND\s+p.created_at <= \$6`).
+		WithArgs(since, w.Comments, w.Reactions, w.Reposts, 100, rankedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7).AddRow(3))
+
+	ids, err := (&PostStore{db}).GetPopular(context.Background(), rankedAt, since, w, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(ids, []int64{7, 3}) {
+		t.Errorf("GetPopular() = %v, want [7 3]", ids)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
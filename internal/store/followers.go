@@ -11,29 +11,44 @@ type FollowersStore struct {
 	db *sql.DB
 }
 
+// Follow makes the follower follow the user, unless there is a block between
+// them.
 func (s *FollowersStore) Follow(ctx context.Context, followerID int64, userID int64) error {
 	query := `
 		INSERT INTO followers (user_id, follower_id)
-		VALUES ($1, $2)
+		SELECT $1, $2
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (user_id = $1 AND blocked_id = $2) OR (user_id = $2 AND blocked_id = $1)
+		)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	res, err := s.db.ExecContext(ctx, query, userID, followerID)
 
 	if err != nil {
 		pqErr, ok := err.(*pq.Error)
 
 		if ok && pqErr.Code == "23505" { // conflict error
 			return ErrConflict
-		} else if pqErr.Code == "23503" { // foreign key violation error
+		} else if ok && pqErr.Code == "23503" { // foreign key violation error
 			return ErrNotFound
 		} else {
 			return err
 		}
 	}
 
+	rowsInserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsInserted == 0 {
+		return ErrBlocked
+	}
+
 	return nil
 }
 
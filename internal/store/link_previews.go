@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// LinkPreview is the Open Graph / Twitter card metadata of a linked page,
+// keyed by the normalized URL of the link.
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	SiteName    string `json:"site_name"`
+	Failed      bool   `json:"-"`
+}
+
+type LinkPreviewStore struct {
+	db *sql.DB
+}
+
+// setPostLinks replaces the links of a post, given as normalized URLs in
+// order of appearance, within the transaction writing the post.
+func setPostLinks(ctx context.Context, tx *sql.Tx, postID int64, urls []string) error {
+	query := `DELETE FROM post_links WHERE post_id = $1`
+	if _, err := tx.ExecContext(ctx, query, postID); err != nil {
+		return err
+	}
+
+	if len(urls) == 0 {
+		return nil
+	}
+
+	query = `
+		INSERT INTO post_links (post_id, url, position)
+		SELECT $1, url, position
+		FROM unnest($2::TEXT[]) WITH ORDINALITY AS links (url, position)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.ExecContext(ctx, query, postID, pq.Array(urls))
+
+	return err
+}
+
+// IsFetched tells whether the page at url was already fetched, successfully
+// or not.
+func (s *LinkPreviewStore) IsFetched(ctx context.Context, url string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM link_previews WHERE url = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var fetched bool
+	err := s.db.QueryRowContext(ctx, query, url).Scan(&fetched)
+
+	return fetched, err
+}
+
+// GetUnfetched lists links of posts whose page was never fetched.
+func (s *LinkPreviewStore) GetUnfetched(ctx context.Context, limit int) ([]string, error) {
+	query := `
+		SELECT DISTINCT pl.url
+		FROM post_links pl
+		LEFT JOIN link_previews lp ON lp.url = pl.url
+		WHERE lp.url IS NULL
+		LIMIT $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	urls := []string{}
+
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// Save stores the preview of a page, replacing any previous one.
+func (s *LinkPreviewStore) Save(ctx context.Context, preview *LinkPreview) error {
+	query := `
+		INSERT INTO link_previews (url, title, description, image_url, site_name, failed)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (url) DO UPDATE
+		SET title = EXCLUDED.title,
+			description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url,
+			site_name = EXCLUDED.site_name,
+			failed = EXCLUDED.failed,
+			fetched_at = NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx, query, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.SiteName, preview.Failed)
+
+	return err
+}
+
+// getPostsLinkPreviews returns the previews of the links of the given posts,
+// in order of appearance, keyed by post ID. Links without a successful
+// preview are left out.
+func getPostsLinkPreviews(ctx context.Context, db *sql.DB, postIDs []int64) (map[int64][]LinkPreview, error) {
+	query := `
+		SELECT pl.post_id, lp.url, lp.title, lp.description, lp.image_url, lp.site_name
+		FROM post_links pl
+		INNER JOIN link_previews lp ON lp.url = pl.url
+		WHERE pl.post_id = ANY($1) AND NOT lp.failed
+		ORDER BY pl.post_id, pl.position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	previews := map[int64][]LinkPreview{}
+
+	for rows.Next() {
+		var (
+			postID  int64
+			preview LinkPreview
+		)
+
+		err := rows.Scan(&postID, &preview.URL, &preview.Title, &preview.Description, &preview.ImageURL, &preview.SiteName)
+		if err != nil {
+			return nil, err
+		}
+
+		previews[postID] = append(previews[postID], preview)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return previews, nil
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxAttachments is the number of media a post can carry.
+const MaxAttachments = 4
+
+type Media struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	PostID *int64 `json:"post_id,omitempty"`
+	// BlobKey and ThumbnailKey locate the files in the blob store.
+	BlobKey      string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Description  string `json:"description"`
+	// Sensitive media are hidden behind a click, like posts with a content
+	// warning. Media of sensitive posts are all sensitive.
+	Sensitive bool   `json:"sensitive"`
+	CreatedAt string `json:"created_at"`
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+func (s *MediaStore) Create(ctx context.Context, media *Media) error {
+	query := `
+		INSERT INTO media (user_id, blob_key, thumbnail_key, url, thumbnail_url, content_type, size, width, height, description, sensitive)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx, query, media.UserID, media.BlobKey, media.ThumbnailKey, media.URL, media.ThumbnailURL,
+		media.ContentType, media.Size, media.Width, media.Height, media.Description, media.Sensitive,
+	).Scan(&media.ID, &media.CreatedAt)
+}
+
+// GetUnattached returns the media among ids that the user uploaded and that
+// are not attached to a post yet, in the order of ids.
+func (s *MediaStore) GetUnattached(ctx context.Context, userID int64, ids []int64) ([]Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media
+		WHERE id = ANY($1) AND user_id = $2 AND post_id IS NULL
+		ORDER BY array_position($1, id)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanMedia(rows)
+}
+
+// GetOrphans lists media that were uploaded before the cutoff and are not
+// attached to any post, either because the post was never created or because
+// it got purged.
+func (s *MediaStore) GetOrphans(ctx context.Context, createdBefore time.Time, limit int) ([]Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media
+		WHERE post_id IS NULL AND created_at <= $1
+		ORDER BY created_at
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanMedia(rows)
+}
+
+// DeleteOrphan deletes a media that is still unattached, reporting
+// ErrNotFound if it got attached to a post in the meantime.
+func (s *MediaStore) DeleteOrphan(ctx context.Context, mediaID int64) error {
+	query := `
+		DELETE FROM media
+		WHERE id = $1 AND post_id IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, mediaID)
+	if err != nil {
+		return err
+	}
+
+	rowsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsDeleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const mediaColumns = `id, user_id, post_id, blob_key, thumbnail_key, url, thumbnail_url,
+	content_type, size, width, height, description, sensitive, created_at`
+
+func scanMedia(rows *sql.Rows) ([]Media, error) {
+	media := []Media{}
+
+	for rows.Next() {
+		var m Media
+
+		err := rows.Scan(
+			&m.ID, &m.UserID, &m.PostID, &m.BlobKey, &m.ThumbnailKey, &m.URL, &m.ThumbnailURL,
+			&m.ContentType, &m.Size, &m.Width, &m.Height, &m.Description, &m.Sensitive, &m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		media = append(media, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// attachMediaToPost attaches the user's unattached media to a post, keeping
+// the order of ids. Media that were attached meanwhile are reported as
+// ErrNotFound.
+func attachMediaToPost(ctx context.Context, tx *sql.Tx, postID int64, userID int64, ids []int64) error {
+	query := `
+		UPDATE media
+		SET post_id = $1, position = array_position($3, id)
+		WHERE id = ANY($3) AND user_id = $2 AND post_id IS NULL
+	`
+
+	result, err := tx.ExecContext(ctx, query, postID, userID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	attached, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if attached != int64(len(ids)) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// getPostsMedia returns the media attached to the given posts, in order,
+// keyed by post ID.
+func getPostsMedia(ctx context.Context, db *sql.DB, postIDs []int64) (map[int64][]Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	media, err := scanMedia(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byPost := map[int64][]Media{}
+	for _, m := range media {
+		byPost[*m.PostID] = append(byPost[*m.PostID], m)
+	}
+
+	return byPost, nil
+}
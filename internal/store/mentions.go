@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tiskae/go-social/internal/content"
+)
+
+// Mention is a user mentioned in a post or comment. Start and End locate the
+// @username in the content, in Unicode code points (End is exclusive).
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// MentionNotification is a post or comment where a user got mentioned.
+type MentionNotification struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	PostTitle string `json:"post_title"`
+	CommentID *int64 `json:"comment_id,omitempty"`
+	Content   string `json:"content"`
+	Author    User   `json:"author"`
+	CreatedAt string `json:"created_at"`
+}
+
+type MentionStore struct {
+	db *sql.DB
+}
+
+// setPostMentions replaces the users mentioned in a post, within the
+// transaction writing the post.
+func setPostMentions(ctx context.Context, tx *sql.Tx, postID int64, mentions []Mention) error {
+	query := `DELETE FROM mentions WHERE post_id = $1 AND comment_id IS NULL`
+	if _, err := tx.ExecContext(ctx, query, postID); err != nil {
+		return err
+	}
+
+	userIDs := mentionedUserIDs(mentions)
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	query = `
+		INSERT INTO mentions (post_id, user_id)
+		SELECT $1, unnest($2::BIGINT[])
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.ExecContext(ctx, query, postID, pq.Array(userIDs))
+
+	return err
+}
+
+// setCommentMentions replaces the users mentioned in a comment, within the
+// transaction writing the comment.
+func setCommentMentions(ctx context.Context, tx *sql.Tx, postID int64, commentID int64, mentions []Mention) error {
+	query := `DELETE FROM mentions WHERE comment_id = $1`
+	if _, err := tx.ExecContext(ctx, query, commentID); err != nil {
+		return err
+	}
+
+	userIDs := mentionedUserIDs(mentions)
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	query = `
+		INSERT INTO mentions (post_id, comment_id, user_id)
+		SELECT $1, $2, unnest($3::BIGINT[])
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.ExecContext(ctx, query, postID, commentID, pq.Array(userIDs))
+
+	return err
+}
+
+// GetByUserID lists the posts and comments mentioning the user, newest first,
+// along with the cursor of the next page (nil on the last page). Mentions in
+// posts the user can no longer see are skipped, as are self-mentions.
+func (s *MentionStore) GetByUserID(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]MentionNotification, *Cursor, error) {
+	query := `
+		SELECT m.id, m.post_id, p.title, m.comment_id, COALESCE(c.content, p.content),
+			u.id, u.username, m.created_at
+		FROM mentions m
+		INNER JOIN posts p ON p.id = m.post_id
+		LEFT JOIN comments c ON c.id = m.comment_id
+		INNER JOIN users u ON u.id = COALESCE(c.user_id, p.user_id)
+		WHERE m.user_id = $1 AND
+			u.id <> $1 AND
+			p.deleted_at IS NULL AND
+			` + postVisibleTo("$1") + ` AND
+			($2::TIMESTAMPTZ IS NULL OR (m.created_at, m.id) < ($2, $3))
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		afterCreatedAt *time.Time
+		afterID        int64
+	)
+
+	if cq.Cursor != nil {
+		afterCreatedAt = &cq.Cursor.CreatedAt
+		afterID = cq.Cursor.ID
+	}
+
+	// fetching one extra row tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, afterCreatedAt, afterID, cq.Limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	mentions := []MentionNotification{}
+	createdAts := []time.Time{}
+
+	for rows.Next() {
+		var (
+			mention   MentionNotification
+			createdAt time.Time
+		)
+
+		err := rows.Scan(
+			&mention.ID, &mention.PostID, &mention.PostTitle, &mention.CommentID, &mention.Content,
+			&mention.Author.ID, &mention.Author.Username, &createdAt,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		mention.CreatedAt = createdAt.Format(time.RFC3339)
+
+		mentions = append(mentions, mention)
+		createdAts = append(createdAts, createdAt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if len(mentions) > cq.Limit {
+		mentions = mentions[:cq.Limit]
+		next = &Cursor{CreatedAt: createdAts[cq.Limit-1], ID: mentions[cq.Limit-1].ID}
+	}
+
+	return mentions, next, nil
+}
+
+// getMentionedUsers returns, for each post (or comment when forComments is
+// set) among ids, the IDs of the users it mentions keyed by username.
+func getMentionedUsers(ctx context.Context, db *sql.DB, ids []int64, forComments bool) (map[int64]map[string]int64, error) {
+	query := `
+		SELECT m.post_id, u.id, u.username
+		FROM mentions m
+		INNER JOIN users u ON u.id = m.user_id
+		WHERE m.post_id = ANY($1) AND m.comment_id IS NULL
+	`
+
+	if forComments {
+		query = `
+			SELECT m.comment_id, u.id, u.username
+			FROM mentions m
+			INNER JOIN users u ON u.id = m.user_id
+			WHERE m.comment_id = ANY($1)
+		`
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	mentioned := map[int64]map[string]int64{}
+
+	for rows.Next() {
+		var (
+			id       int64
+			userID   int64
+			username string
+		)
+
+		if err := rows.Scan(&id, &userID, &username); err != nil {
+			return nil, err
+		}
+
+		if mentioned[id] == nil {
+			mentioned[id] = map[string]int64{}
+		}
+
+		mentioned[id][username] = userID
+	}
+
+	return mentioned, rows.Err()
+}
+
+// BuildMentions locates the mentions of the given users, keyed by username,
+// in text. Usernames that didn't resolve to a user are left out.
+func BuildMentions(text string, users map[string]int64) []Mention {
+	mentions := []Mention{}
+
+	for _, found := range content.FindMentions(text) {
+		userID, ok := users[found.Username]
+		if !ok {
+			continue
+		}
+
+		mentions = append(mentions, Mention{
+			UserID:   userID,
+			Username: found.Username,
+			Start:    found.Start,
+			End:      found.End,
+		})
+	}
+
+	return mentions
+}
+
+func mentionedUserIDs(mentions []Mention) []int64 {
+	seen := map[int64]bool{}
+	userIDs := []int64{}
+
+	for _, mention := range mentions {
+		if !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			userIDs = append(userIDs, mention.UserID)
+		}
+	}
+
+	return userIDs
+}
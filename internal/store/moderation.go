@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const (
+	ModerationForceContentWarning  = "force_content_warning"
+	ModerationRemoveContentWarning = "remove_content_warning"
+)
+
+// ModerationAction is the audit record of a moderator acting on a post.
+// PreviousValue and NewValue hold the post's content warning before and
+// after the action.
+type ModerationAction struct {
+	ID            int64  `json:"id"`
+	ModeratorID   int64  `json:"moderator_id"`
+	PostID        int64  `json:"post_id"`
+	Action        string `json:"action"`
+	Reason        string `json:"reason"`
+	PreviousValue string `json:"previous_value"`
+	NewValue      string `json:"new_value"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type ModerationStore struct {
+	db *sql.DB
+}
+
+// SetContentWarning puts the content warning in action.NewValue on a post on
+// behalf of a moderator, or lifts it when NewValue is empty, and records the
+// action. A forced content warning can't be changed by the post's author.
+// Forcing a content warning with sensitive set also marks the post's media as
+// sensitive.
+func (s *ModerationStore) SetContentWarning(ctx context.Context, post *Post, sensitive bool, action *ModerationAction) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `SELECT content_warning FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+
+		if err := tx.QueryRowContext(ctx, query, post.ID).Scan(&action.PreviousValue); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `
+			UPDATE posts
+			SET content_warning = $2, sensitive = sensitive OR $3, content_warning_forced = $2 <> '', version = version + 1
+			WHERE id = $1
+			RETURNING content_warning, sensitive, content_warning_forced, version
+		`
+
+		err := tx.QueryRowContext(ctx, query, post.ID, action.NewValue, sensitive).
+			Scan(&post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.Version)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO moderation_actions (moderator_id, post_id, action, reason, previous_value, new_value)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at
+		`
+
+		action.PostID = post.ID
+
+		return tx.QueryRowContext(
+			ctx, query, action.ModeratorID, action.PostID, action.Action, action.Reason, action.PreviousValue, action.NewValue,
+		).Scan(&action.ID, &action.CreatedAt)
+	})
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// MuteStore keeps the users each user muted. The posts of a muted user are
+// left out of the muter's home feed and tag feeds, but can still be opened,
+// and the two users can still follow each other.
+type MuteStore struct {
+	db *sql.DB
+}
+
+func (s *MuteStore) Mute(ctx context.Context, userID int64, mutedID int64) error {
+	query := `
+		INSERT INTO user_mutes (user_id, muted_id)
+		VALUES ($1, $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505": // conflict error
+				return ErrConflict
+			case "23503": // foreign key violation error
+				return ErrNotFound
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *MuteStore) Unmute(ctx context.Context, userID int64, mutedID int64) error {
+	query := `
+		DELETE FROM user_mutes
+		WHERE user_id = $1 AND muted_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestMute(t *testing.T) {
+	tests := []struct {
+		name    string
+		execErr error
+		want    error
+	}{
+		{"muted", nil, nil},
+		{"already muted", &pq.Error{Code: "23505"}, ErrConflict},
+		{"unknown user", &pq.Error{Code: "23503"}, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			exec := mock.ExpectExec(`INSERT INTO user_mutes`).WithArgs(1, 2)
+			if tt.execErr != nil {
+				exec.WillReturnError(tt.execErr)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			err = (&MuteStore{db}).Mute(context.Background(), 1, 2)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Mute() error = %v, want %v", err, tt.want)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUnmute(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     error
+	}{
+		{"unmuted", 1, nil},
+		{"not muted", 0, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec(`DELETE FROM user_mutes`).
+				WithArgs(1, 2).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			err = (&MuteStore{db}).Unmute(context.Background(), 1, 2)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Unmute() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// errQueryChecked ends a store call once its query matched, for tests that
+// only check the shape of a query.
+var errQueryChecked = errors.New("query checked")
+
+// A mute only applies to the lists of posts the viewer didn't ask for by
+// author: the posts and reposts of muted users are left out of the home feed
+// and tag feeds.
+func TestGetByTagLeavesOutMutedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`NOT EXISTS \(SELECT 1 FROM user_mutes um WHERE um.user_id = \$2 AND um.muted_id = p.user_id\)`).
+		WithArgs("go", 1, nil, 0, 21).
+		WillReturnError(errQueryChecked)
+
+	_, _, err = (&PostStore{db}).GetByTag(context.Background(), "go", 1, PaginatedCursorQuery{Limit: 20})
+	if !errors.Is(err, errQueryChecked) {
+		t.Fatalf("GetByTag() error = %v, want the query to be checked", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetFeedPostsLeavesOutMutedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// reposts by muted users don't bring a post in either
+	mock.ExpectQuery(`(?s)r.user_id NOT IN \(SELECT muted_id FROM user_mutes WHERE user_id = \$2\).*um.muted_id = p.user_id`).
+		WillReturnError(errQueryChecked)
+
+	_, err = (&PostStore{db}).GetFeedPosts(context.Background(), 1, []TimelineEntry{{PostID: 3}})
+	if !errors.Is(err, errQueryChecked) {
+		t.Fatalf("GetFeedPosts() error = %v, want the query to be checked", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
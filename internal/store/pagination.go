@@ -1,20 +1,29 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/tiskae/go-social/internal/content"
 )
 
+// PaginatedFeedQuery pages through a feed either with Offset, or with Cursor
+// once one is given, which is the default when Offset isn't. The ranked sort
+// has cursors of its own, holding the time of the ranking and a position.
 type PaginatedFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
+	Cursor *Cursor  `json:"cursor" validate:"excluded_with=Offset"`
+	Sort   string   `json:"sort" validate:"oneof=asc desc ranked"`
 	Tags   []string `json:"tags" validate:"dive,max=20"`
 	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"until"`
+	// Since and Until bound the time of the posts, both inclusive.
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -42,14 +51,31 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Offset = of
 	}
 
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return fq, err
+		}
+
+		fq.Cursor = &c
+	}
+
 	sort := qs.Get("sort")
 	if sort != "" {
 		fq.Sort = sort
 	}
 
+	// a ranked cursor holds a position in a ranking, while the others hold a
+	// post, so a cursor only goes with the sort it was made for
+	if fq.Cursor != nil && fq.Cursor.Sort != fq.Sort {
+		return fq, ErrCursorSortMismatch
+	}
+
 	tags := qs.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, ",")
+		fq.Tags = content.MergeTags(strings.Split(tags, ","))
 	}
 
 	search := qs.Get("search")
@@ -57,24 +83,515 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 		fq.Search = search
 	}
 
-	since := qs.Get("since")
+	var err error
+
+	fq.Since, fq.Until, err = parseTimeRange(qs.Get("since"), qs.Get("until"), time.Now())
+	if err != nil {
+		return fq, err
+	}
+
+	return fq, nil
+}
+
+var ErrCursorSortMismatch = errors.New("cursor was made for another sort")
+
+var ErrInvalidTimeRange = errors.New("since must not be after until")
+
+// parseTimeRange parses the since and until bounds of a time range, either of
+// which may be left empty.
+func parseTimeRange(since string, until string, now time.Time) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+
 	if since != "" {
-		fq.Since = parseTime(since)
+		t, err := ParseTimeFilter(since, now)
+		if err != nil {
+			return nil, nil, fmt.Errorf("since: %w", err)
+		}
+
+		from = &t
 	}
 
-	until := qs.Get("until")
 	if until != "" {
-		fq.Until = parseTime(until)
+		t, err := ParseTimeFilter(until, now)
+		if err != nil {
+			return nil, nil, fmt.Errorf("until: %w", err)
+		}
+
+		to = &t
 	}
 
-	return fq, nil
+	if from != nil && to != nil && from.After(*to) {
+		return nil, nil, ErrInvalidTimeRange
+	}
+
+	return from, to, nil
+}
+
+var ErrInvalidTime = errors.New("time must be in RFC 3339 (2006-01-02T15:04:05Z07:00) or 2006-01-02 15:04:05 (UTC) format, or a duration ago such as 30m, 24h or 7d")
+
+// ParseTimeFilter parses a time given in RFC 3339, in time.DateTime format
+// (taken as UTC), or relative to now as a positive duration ago: a Go
+// duration such as "90m" or "24h", or a number of days or weeks such as "7d"
+// or "2w".
+func ParseTimeFilter(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.DateTime, s); err == nil {
+		return t, nil
+	}
+
+	var ago time.Duration
+
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return time.Time{}, ErrInvalidTime
+		}
+
+		ago = time.Duration(days) * 24 * time.Hour
+	} else if n, ok := strings.CutSuffix(s, "w"); ok {
+		weeks, err := strconv.Atoi(n)
+		if err != nil {
+			return time.Time{}, ErrInvalidTime
+		}
+
+		ago = time.Duration(weeks) * 7 * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, ErrInvalidTime
+		}
+
+		ago = d
+	}
+
+	if ago <= 0 {
+		return time.Time{}, ErrInvalidTime
+	}
+
+	return now.Add(-ago), nil
+}
+
+// sortOrders maps the sort query param of offset-paged lists to the SQL
+// keyword it stands for, so that it is never written into a query as given.
+var sortOrders = map[string]string{
+	"asc":  "ASC",
+	"desc": "DESC",
+}
+
+// PaginatedListQuery pages through lists with no filters, such as drafts and
+// the trash, with Offset.
+type PaginatedListQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+}
+
+// ErrUnsupportedFilter is returned for feed filters given to a list that
+// can't apply them.
+var ErrUnsupportedFilter = errors.New("filter not supported on this list")
+
+func (lq PaginatedListQuery) Parse(r *http.Request) (PaginatedListQuery, error) {
+	qs := r.URL.Query()
+
+	// the filters of the feeds are refused rather than ignored, so that
+	// nobody mistakes an unfiltered list for a filtered one
+	for _, param := range []string{"cursor", "tags", "search", "since", "until"} {
+		if qs.Has(param) {
+			return lq, fmt.Errorf("%s: %w", param, ErrUnsupportedFilter)
+		}
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return lq, err
+		}
+
+		lq.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		of, err := strconv.Atoi(offset)
+
+		if err != nil {
+			return lq, err
+		}
+
+		lq.Offset = of
+	}
+
+	sort := qs.Get("sort")
+	if sort != "" {
+		lq.Sort = sort
+	}
+
+	return lq, nil
+}
+
+// order returns the SQL keyword of lq.Sort, descending unless it is asc.
+func (lq PaginatedListQuery) order() string {
+	if order, ok := sortOrders[lq.Sort]; ok {
+		return order
+	}
+
+	return "DESC"
+}
+
+type PaginatedBookmarksQuery struct {
+	Limit    int     `json:"limit" validate:"gte=1,lte=20"`
+	Cursor   *Cursor `json:"cursor"`
+	FolderID *int64  `json:"folder_id" validate:"omitempty,gte=1"`
+}
+
+func (bq PaginatedBookmarksQuery) Parse(r *http.Request) (PaginatedBookmarksQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return bq, err
+		}
+
+		bq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return bq, err
+		}
+
+		bq.Cursor = &c
+	}
+
+	folderID := qs.Get("folder_id")
+	if folderID != "" {
+		id, err := strconv.ParseInt(folderID, 10, 64)
+
+		if err != nil {
+			return bq, err
+		}
+
+		bq.FolderID = &id
+	}
+
+	return bq, nil
+}
+
+type PaginatedCursorQuery struct {
+	Limit  int     `json:"limit" validate:"gte=1,lte=20"`
+	Cursor *Cursor `json:"cursor"`
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
+func (cq PaginatedCursorQuery) Parse(r *http.Request) (PaginatedCursorQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Cursor = &c
+	}
+
+	return cq, nil
+}
+
+type ThreadQuery struct {
+	Depth int `json:"depth" validate:"gte=1,lte=10"`
+	Limit int `json:"limit" validate:"gte=1,lte=200"`
+}
+
+func (tq ThreadQuery) Parse(r *http.Request) (ThreadQuery, error) {
+	qs := r.URL.Query()
+
+	depth := qs.Get("depth")
+	if depth != "" {
+		d, err := strconv.Atoi(depth)
+
+		if err != nil {
+			return tq, err
+		}
+
+		tq.Depth = d
+	}
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return tq, err
+		}
+
+		tq.Limit = l
+	}
+
+	return tq, nil
+}
+
+type UserPostsQuery struct {
+	Limit          int        `json:"limit" validate:"gte=1,lte=20"`
+	Cursor         *Cursor    `json:"cursor"`
+	Tags           []string   `json:"tags" validate:"dive,max=20"`
+	Search         string     `json:"search" validate:"max=100"`
+	Since          *time.Time `json:"since"`
+	Until          *time.Time `json:"until"`
+	IncludeReplies bool       `json:"include_replies"`
+	IncludeReposts bool       `json:"include_reposts"`
+}
+
+// Filtered tells whether the query narrows down the user's posts, in which
+// case pinned posts are listed along with the others rather than on top.
+func (uq UserPostsQuery) Filtered() bool {
+	return len(uq.Tags) > 0 || uq.Search != "" || uq.Since != nil || uq.Until != nil
+}
+
+func (uq UserPostsQuery) Parse(r *http.Request) (UserPostsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return uq, err
+		}
+
+		uq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return uq, err
+		}
+
+		uq.Cursor = &c
+	}
+
+	tags := qs.Get("tags")
+	if tags != "" {
+		uq.Tags = content.MergeTags(strings.Split(tags, ","))
+	}
+
+	search := qs.Get("search")
+	if search != "" {
+		uq.Search = search
+	}
+
+	var err error
+
+	uq.Since, uq.Until, err = parseTimeRange(qs.Get("since"), qs.Get("until"), time.Now())
 	if err != nil {
-		return ""
+		return uq, err
+	}
+
+	includeReplies := qs.Get("include_replies")
+	if includeReplies != "" {
+		b, err := strconv.ParseBool(includeReplies)
+
+		if err != nil {
+			return uq, err
+		}
+
+		uq.IncludeReplies = b
+	}
+
+	includeReposts := qs.Get("include_reposts")
+	if includeReposts != "" {
+		b, err := strconv.ParseBool(includeReposts)
+
+		if err != nil {
+			return uq, err
+		}
+
+		uq.IncludeReposts = b
+	}
+
+	return uq, nil
+}
+
+type ExploreQuery struct {
+	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
+	Cursor *Cursor  `json:"cursor"`
+	Tags   []string `json:"tags" validate:"dive,max=20"`
+	Search string   `json:"search" validate:"max=100"`
+}
+
+func (eq ExploreQuery) Parse(r *http.Request) (ExploreQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return eq, err
+		}
+
+		eq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return eq, err
+		}
+
+		eq.Cursor = &c
+	}
+
+	tags := qs.Get("tags")
+	if tags != "" {
+		eq.Tags = content.MergeTags(strings.Split(tags, ","))
+	}
+
+	search := qs.Get("search")
+	if search != "" {
+		eq.Search = search
+	}
+
+	return eq, nil
+}
+
+// PopularQuery pages through the posts with the most engagement over the
+// last Hours hours. Its cursors hold the position of the page and the time
+// the posts were ranked at.
+type PopularQuery struct {
+	Limit  int     `json:"limit" validate:"gte=1,lte=20"`
+	Cursor *Cursor `json:"cursor"`
+	Hours  int     `json:"hours" validate:"gte=1,lte=168"`
+}
+
+func (popq PopularQuery) Parse(r *http.Request) (PopularQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return popq, err
+		}
+
+		popq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return popq, err
+		}
+
+		popq.Cursor = &c
+	}
+
+	hours := qs.Get("hours")
+	if hours != "" {
+		h, err := strconv.Atoi(hours)
+
+		if err != nil {
+			return popq, err
+		}
+
+		popq.Hours = h
+	}
+
+	return popq, nil
+}
+
+// CommentsQuery pages through comments, each with a tree of its replies Depth
+// levels down where every comment shows at most RepliesLimit replies.
+type CommentsQuery struct {
+	Limit        int     `json:"limit" validate:"gte=1,lte=50"`
+	Cursor       *Cursor `json:"cursor"`
+	Sort         string  `json:"sort" validate:"oneof=oldest newest most_reacted"`
+	Depth        int     `json:"depth" validate:"gte=1,lte=10"`
+	RepliesLimit int     `json:"replies_limit" validate:"gte=1,lte=20"`
+}
+
+func (cq CommentsQuery) Parse(r *http.Request) (CommentsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Limit = l
+	}
+
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Cursor = &c
+	}
+
+	sort := qs.Get("sort")
+	if sort != "" {
+		cq.Sort = sort
+	}
+
+	depth := qs.Get("depth")
+	if depth != "" {
+		d, err := strconv.Atoi(depth)
+
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Depth = d
+	}
+
+	repliesLimit := qs.Get("replies_limit")
+	if repliesLimit != "" {
+		l, err := strconv.Atoi(repliesLimit)
+
+		if err != nil {
+			return cq, err
+		}
+
+		cq.RepliesLimit = l
 	}
 
-	return t.Format(time.DateTime)
+	return cq, nil
 }
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+func TestParseTimeFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr error
+	}{
+		{"rfc3339", "2026-03-01T08:30:00Z", time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), nil},
+		{"rfc3339 with offset", "2026-03-01T08:30:00+02:00", time.Date(2026, 3, 1, 6, 30, 0, 0, time.UTC), nil},
+		{"date time taken as utc", "2026-03-01 08:30:00", time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), nil},
+		{"minutes", "90m", testNow.Add(-90 * time.Minute), nil},
+		{"hours", "2h", testNow.Add(-2 * time.Hour), nil},
+		{"days", "7d", testNow.AddDate(0, 0, -7), nil},
+		{"weeks", "2w", testNow.AddDate(0, 0, -14), nil},
+		{"unknown unit", "3y", time.Time{}, ErrInvalidTime},
+		{"missing number", "d", time.Time{}, ErrInvalidTime},
+		{"fractional days", "1.5d", time.Time{}, ErrInvalidTime},
+		{"negative duration", "-2h", time.Time{}, ErrInvalidTime},
+		{"negative days", "-7d", time.Time{}, ErrInvalidTime},
+		{"zero", "0d", time.Time{}, ErrInvalidTime},
+		{"date only", "2026-03-01", time.Time{}, ErrInvalidTime},
+		{"garbage", "yesterday", time.Time{}, ErrInvalidTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimeFilter(tt.in, testNow)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseTimeFilter(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTimeFilter(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		since, until string
+		wantErr      error
+	}{
+		{"both", "7d", "1d", nil},
+		{"since only", "2026-03-01T00:00:00Z", "", nil},
+		{"until only", "", "2h", nil},
+		{"equal bounds", "2026-03-01T00:00:00Z", "2026-03-01T00:00:00Z", nil},
+		{"since after until", "1d", "7d", ErrInvalidTimeRange},
+		{"absolute since after until", "2026-03-02T00:00:00Z", "2026-03-01T00:00:00Z", ErrInvalidTimeRange},
+		{"invalid since", "3y", "", ErrInvalidTime},
+		{"invalid until", "", "-1h", ErrInvalidTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := parseTimeRange(tt.since, tt.until, testNow)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseTimeRange(%q, %q) error = %v, want %v", tt.since, tt.until, err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if (from != nil) != (tt.since != "") || (to != nil) != (tt.until != "") {
+				t.Errorf("parseTimeRange(%q, %q) = %v, %v, want bounds set only when given", tt.since, tt.until, from, to)
+			}
+		})
+	}
+}
+
+func TestPaginatedListQueryParse(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    PaginatedListQuery
+		wantErr error
+	}{
+		{"", PaginatedListQuery{Limit: 20, Sort: "desc"}, nil},
+		{"limit=5&offset=10&sort=asc", PaginatedListQuery{Limit: 5, Offset: 10, Sort: "asc"}, nil},
+		{"tags=go", PaginatedListQuery{}, ErrUnsupportedFilter},
+		{"search=x", PaginatedListQuery{}, ErrUnsupportedFilter},
+		{"since=7d", PaginatedListQuery{}, ErrUnsupportedFilter},
+		{"until=", PaginatedListQuery{}, ErrUnsupportedFilter},
+		{"cursor=abc", PaginatedListQuery{}, ErrUnsupportedFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/posts/drafts?"+tt.query, nil)
+
+			got, err := PaginatedListQuery{Limit: 20, Sort: "desc"}.Parse(r)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.query, err, tt.wantErr)
+			}
+
+			if err == nil && got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// Only the SQL keywords of the known sorts ever make it into a query.
+func TestPaginatedListQueryOrder(t *testing.T) {
+	tests := map[string]string{
+		"asc":               "ASC",
+		"desc":              "DESC",
+		"ranked":            "DESC",
+		"; DROP TABLE x --": "DESC",
+	}
+
+	for sort, want := range tests {
+		if got := (PaginatedListQuery{Sort: sort}).order(); got != want {
+			t.Errorf("order() of sort %q = %q, want %q", sort, got, want)
+		}
+	}
+}
+
+func TestPaginatedFeedQueryCursorSort(t *testing.T) {
+	ranked := Cursor{CreatedAt: testNow, Count: 20, Sort: "ranked"}
+	desc := Cursor{CreatedAt: testNow, ID: 7, Sort: "desc"}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr error
+	}{
+		{"ranked cursor on ranked feed", "sort=ranked&cursor=" + ranked.Encode(), nil},
+		{"desc cursor on default sort", "cursor=" + desc.Encode(), nil},
+		{"ranked cursor on default sort", "cursor=" + ranked.Encode(), ErrCursorSortMismatch},
+		{"desc cursor on ranked feed", "sort=ranked&cursor=" + desc.Encode(), ErrCursorSortMismatch},
+		{"desc cursor on asc sort", "sort=asc&cursor=" + desc.Encode(), ErrCursorSortMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/feed?"+tt.query, nil)
+
+			_, err := PaginatedFeedQuery{Limit: 20, Sort: "desc"}.Parse(r)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
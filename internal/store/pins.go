@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// MaxPinnedPosts is the number of posts a user can pin to their profile.
+const MaxPinnedPosts = 3
+
+var (
+	ErrTooManyPins  = errors.New("pinned posts limit reached")
+	ErrPinsMismatch = errors.New("post ids must list every pinned post once")
+)
+
+type PinStore struct {
+	db *sql.DB
+}
+
+// Pin pins a post at the top of the user's pins. Pinning a post twice is
+// reported as ErrConflict, and pinning more than MaxPinnedPosts posts as
+// ErrTooManyPins.
+func (s *PinStore) Pin(ctx context.Context, userID int64, postID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		pinned, err := lockPins(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if slices.Contains(pinned, postID) {
+			return ErrConflict
+		}
+
+		if len(pinned) >= MaxPinnedPosts {
+			return ErrTooManyPins
+		}
+
+		query := `UPDATE pinned_posts SET position = position + 1 WHERE user_id = $1`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO pinned_posts (user_id, post_id, position)
+			VALUES ($1, $2, 0)
+		`
+
+		if _, err := tx.ExecContext(ctx, query, userID, postID); err != nil {
+			pqErr, ok := err.(*pq.Error)
+
+			switch {
+			case ok && pqErr.Code == "23503": // foreign key violation error
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *PinStore) Unpin(ctx context.Context, userID int64, postID int64) error {
+	query := `
+		DELETE FROM pinned_posts
+		WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rowsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsDeleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Reorder sorts the user's pins in the order of postIDs, which must list
+// every pinned post exactly once (ErrPinsMismatch otherwise).
+func (s *PinStore) Reorder(ctx context.Context, userID int64, postIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		pinned, err := lockPins(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		sorted := slices.Clone(postIDs)
+		slices.Sort(sorted)
+		slices.Sort(pinned)
+
+		if !slices.Equal(sorted, pinned) {
+			return ErrPinsMismatch
+		}
+
+		query := `
+			UPDATE pinned_posts
+			SET position = array_position($2, post_id)
+			WHERE user_id = $1 AND post_id = ANY($2)
+		`
+
+		_, err = tx.ExecContext(ctx, query, userID, pq.Array(postIDs))
+
+		return err
+	})
+}
+
+// lockPins returns the IDs of the posts pinned by the user, leaving out
+// deleted posts whose pins only come back if they're restored. It locks the
+// user's row so that concurrent changes to their pins run one at a time.
+func lockPins(ctx context.Context, tx *sql.Tx, userID int64) ([]int64, error) {
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+
+	var pinned []int64
+
+	query := `
+		SELECT COALESCE(ARRAY_AGG(pp.post_id), '{}')
+		FROM pinned_posts pp
+		INNER JOIN posts p ON p.id = pp.post_id
+		WHERE pp.user_id = $1 AND p.deleted_at IS NULL
+	`
+	if err := tx.QueryRowContext(ctx, query, userID).Scan(pq.Array(&pinned)); err != nil {
+		return nil, err
+	}
+
+	return pinned, nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Poll is attached to a post. The tallies (VotersCount and the options'
+// VotesCount) are left out when the results are hidden from the viewer.
+type Poll struct {
+	MultipleChoice bool         `json:"multiple_choice"`
+	HideResults    bool         `json:"hide_results"`
+	ExpiresAt      string       `json:"expires_at"`
+	Expired        bool         `json:"expired"`
+	Options        []PollOption `json:"options"`
+	VotersCount    *int         `json:"voters_count"`
+	// Voted tells whether the viewer voted, OwnVotes lists the options they
+	// picked.
+	Voted    bool    `json:"voted"`
+	OwnVotes []int64 `json:"own_votes"`
+}
+
+type PollOption struct {
+	ID         int64  `json:"id"`
+	Text       string `json:"text"`
+	VotesCount *int   `json:"votes_count"`
+}
+
+// hideResults drops the tallies unless the viewer may see them: results
+// hidden by the poll are shown to its author, to voters and once it ended.
+func (p *Poll) hideResults(viewerID, authorID int64) {
+	if !p.HideResults || p.Expired || p.Voted || viewerID == authorID {
+		return
+	}
+
+	p.VotersCount = nil
+	for i := range p.Options {
+		p.Options[i].VotesCount = nil
+	}
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+// Vote records the user's vote for the given options of a post's poll. Voting
+// twice is reported as ErrConflict and options that aren't part of the poll
+// as ErrNotFound.
+func (s *PollStore) Vote(ctx context.Context, postID int64, userID int64, optionIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `INSERT INTO poll_voters (post_id, user_id) VALUES ($1, $2)`
+
+		if _, err := tx.ExecContext(ctx, query, postID, userID); err != nil {
+			pqErr, ok := err.(*pq.Error)
+
+			switch {
+			case ok && pqErr.Code == "23505": // conflict error
+				return ErrConflict
+			case ok && pqErr.Code == "23503": // foreign key violation error
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `
+			INSERT INTO poll_votes (option_id, user_id)
+			SELECT id, $3 FROM poll_options
+			WHERE post_id = $1 AND id = ANY($2)
+		`
+
+		result, err := tx.ExecContext(ctx, query, postID, pq.Array(optionIDs), userID)
+		if err != nil {
+			return err
+		}
+
+		voted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if voted != int64(len(optionIDs)) {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// createPoll stores the poll of a new post, filling in the IDs of its
+// options.
+func createPoll(ctx context.Context, tx *sql.Tx, postID int64, poll *Poll) error {
+	query := `
+		INSERT INTO polls (post_id, multiple_choice, hide_results, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING expires_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, postID, poll.MultipleChoice, poll.HideResults, poll.ExpiresAt).
+		Scan(&poll.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO poll_options (post_id, position, text)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	poll.VotersCount = new(int)
+	poll.OwnVotes = []int64{}
+
+	for i := range poll.Options {
+		option := &poll.Options[i]
+
+		if err := tx.QueryRowContext(ctx, query, postID, i, option.Text).Scan(&option.ID); err != nil {
+			return err
+		}
+
+		option.VotesCount = new(int)
+	}
+
+	return nil
+}
+
+// getPolls returns the polls of the given posts, keyed by post ID, with the
+// votes of the viewer. Results are not hidden yet.
+func getPolls(ctx context.Context, db *sql.DB, postIDs []int64, viewerID int64) (map[int64]*Poll, error) {
+	query := `
+		SELECT pl.post_id, pl.multiple_choice, pl.hide_results, pl.expires_at,
+			(SELECT COUNT(*) FROM poll_voters v WHERE v.post_id = pl.post_id) voters_count,
+			EXISTS (SELECT 1 FROM poll_voters v WHERE v.post_id = pl.post_id AND v.user_id = $2) voted
+		FROM polls pl
+		WHERE pl.post_id = ANY($1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	polls := map[int64]*Poll{}
+	now := time.Now()
+
+	for rows.Next() {
+		var (
+			postID      int64
+			expiresAt   time.Time
+			votersCount int
+			poll        = &Poll{Options: []PollOption{}, OwnVotes: []int64{}}
+		)
+
+		err := rows.Scan(&postID, &poll.MultipleChoice, &poll.HideResults, &expiresAt, &votersCount, &poll.Voted)
+		if err != nil {
+			return nil, err
+		}
+
+		poll.ExpiresAt = expiresAt.Format(time.RFC3339)
+		poll.Expired = !expiresAt.After(now)
+		poll.VotersCount = &votersCount
+
+		polls[postID] = poll
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(polls) == 0 {
+		return polls, nil
+	}
+
+	query = `
+		SELECT o.id, o.post_id, o.text,
+			(SELECT COUNT(*) FROM poll_votes pv WHERE pv.option_id = o.id) votes_count,
+			EXISTS (SELECT 1 FROM poll_votes pv WHERE pv.option_id = o.id AND pv.user_id = $2) own_vote
+		FROM poll_options o
+		WHERE o.post_id = ANY($1)
+		ORDER BY o.post_id, o.position
+	`
+
+	rows, err = db.QueryContext(ctx, query, pq.Array(postIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			option     PollOption
+			postID     int64
+			votesCount int
+			ownVote    bool
+		)
+
+		if err := rows.Scan(&option.ID, &postID, &option.Text, &votesCount, &ownVote); err != nil {
+			return nil, err
+		}
+
+		option.VotesCount = &votesCount
+
+		poll := polls[postID]
+		poll.Options = append(poll.Options, option)
+		if ownVote {
+			poll.OwnVotes = append(poll.OwnVotes, option.ID)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return polls, nil
+}
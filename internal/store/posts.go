@@ -5,49 +5,179 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/tiskae/go-social/internal/content"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityMentioned = "mentioned"
+)
+
+const (
+	CommentPolicyEveryone  = "everyone"
+	CommentPolicyFollowers = "followers"
+	CommentPolicyMentioned = "mentioned"
+	CommentPolicyClosed    = "closed"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	Tags      []string  `json:"tags"`
-	UserID    int64     `json:"user_id"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Comments  []Comment `json:"comments"`
-	Version   int       `json:"version"`
-	User      User      `json:"user"`
+	ID         int64      `json:"id"`
+	Content    string     `json:"content"`
+	Title      string     `json:"title"`
+	Tags       []string   `json:"tags"`
+	UserID     int64      `json:"user_id"`
+	CreatedAt  string     `json:"created_at"`
+	UpdatedAt  string     `json:"updated_at"`
+	Comments   []*Comment `json:"comments,omitempty"`
+	Version    int        `json:"version"`
+	User       User       `json:"user"`
+	DeletedAt  *string    `json:"deleted_at,omitempty"`
+	Status     string     `json:"status"`
+	PublishAt  *string    `json:"publish_at,omitempty"`
+	Visibility string     `json:"visibility"`
+	// ContentFormat tells how Content is written (plain or markdown).
+	// ContentHTML is its sanitized rendering, computed once on write.
+	ContentFormat string `json:"content_format"`
+	ContentHTML   string `json:"content_html"`
+	// QuotedPostID is set on quote posts. QuotedPost is left empty when the
+	// quoted post was deleted or is not visible to the viewer.
+	QuotedPostID *int64 `json:"quoted_post_id,omitempty"`
+	QuotedPost   *Post  `json:"quoted_post,omitempty"`
+	// InReplyToPostID is set on replies, threads are built from it.
+	InReplyToPostID *int64    `json:"in_reply_to_post_id,omitempty"`
+	BookmarkedByMe  bool      `json:"bookmarked_by_me"`
+	Mentions        []Mention `json:"mentions"`
+	Attachments     []Media   `json:"attachments"`
+	// LinkPreviews only lists the links whose page was fetched already.
+	LinkPreviews []LinkPreview `json:"link_previews"`
+	Poll         *Poll         `json:"poll,omitempty"`
+	// ContentWarning is shown in place of the content until the reader
+	// expands the post. Sensitive marks all the post's media as sensitive.
+	// ContentWarningForced is set when a moderator put the content warning.
+	ContentWarning       string `json:"content_warning"`
+	Sensitive            bool   `json:"sensitive"`
+	ContentWarningForced bool   `json:"content_warning_forced"`
+	// Collapsed tells whether the post should be hidden behind a click for the
+	// viewer, depending on their preferences.
+	Collapsed bool `json:"collapsed"`
+	// CommentPolicy tells who besides the author can comment: everyone,
+	// followers of the author, users mentioned in the post, or nobody
+	// (closed).
+	CommentPolicy string `json:"comment_policy"`
 }
 
 type PostWithMetadata struct {
 	Post
-	CommentsCount int `json:"comments_count"`
+	CommentsCount int     `json:"comments_count"`
+	Pinned        bool    `json:"pinned"`
+	RepostedBy    *User   `json:"reposted_by,omitempty"`
+	RepostedAt    *string `json:"reposted_at,omitempty"`
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
-func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+// postVisibleTo returns the SQL condition limiting posts aliased as p to the
+// ones the viewer bound at the given placeholder may see: their own posts,
+// and published posts that are public, followers-only where the viewer
+// follows the author, or that mention the viewer, as long as there is no
+// block between the viewer and the author.
+func postVisibleTo(viewer string) string {
+	return `(p.user_id = ` + viewer + ` OR (p.status = 'published' AND NOT EXISTS (
+			SELECT 1 FROM user_blocks ub
+			WHERE (ub.user_id = ` + viewer + ` AND ub.blocked_id = p.user_id) OR
+				(ub.user_id = p.user_id AND ub.blocked_id = ` + viewer + `)) AND (
+			p.visibility = 'public' OR
+			(p.visibility = 'followers' AND EXISTS (
+				SELECT 1 FROM followers fv WHERE fv.user_id = p.user_id AND fv.follower_id = ` + viewer + `)) OR
+			EXISTS (SELECT 1 FROM mentions m
+				WHERE m.post_id = p.id AND m.comment_id IS NULL AND m.user_id = ` + viewer + `))))`
+}
+
+// postNotMuted returns the SQL condition leaving out the posts aliased as p
+// of the users muted by the viewer bound at the given placeholder, for the
+// lists of posts the viewer didn't ask for by author.
+func postNotMuted(viewer string) string {
+	return `NOT EXISTS (SELECT 1 FROM user_mutes um WHERE um.user_id = ` + viewer + ` AND um.muted_id = p.user_id)`
+}
+
+// feedItems are the common table expressions listing the items of the home
+// feed of the user bound to $1 as latest_items, one per post for its most
+// recent activity.
+const feedItems = `
+		feed_items AS (
+			SELECT p.id AS post_id, NULL::BIGINT AS reposted_by, p.created_at AS activity_at
+			FROM posts p
+			WHERE p.user_id = $1 OR p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)
+			UNION ALL
+			SELECT r.post_id, r.user_id, r.created_at
+			FROM reposts r
+			WHERE (r.user_id = $1 OR r.user_id IN (SELECT user_id FROM followers WHERE follower_id = $1)) AND
+				r.user_id NOT IN (SELECT muted_id FROM user_mutes WHERE user_id = $1)
+			UNION ALL
+			SELECT p.id, NULL::BIGINT, p.created_at
+			FROM posts p
+			WHERE p.tags && ARRAY(SELECT tag FROM tag_follows WHERE user_id = $1)
+		),
+		latest_items AS (
+			SELECT DISTINCT ON (post_id) post_id, reposted_by, activity_at
+			FROM feed_items
+			ORDER BY post_id, activity_at DESC
+		)`
+
+// GetUserFeed returns the posts and reposts of the user and the accounts they
+// follow, along with the posts carrying a tag they follow. A post showing up
+// several times (reposted by several accounts, or both posted by a followed
+// account and tagged with a followed tag) is only listed once, for its most
+// recent activity. Posts of muted users and reposts by them are left out.
+//
+// Posts are ordered by (activity time, id), and paged through with fq.Cursor,
+// or fq.Offset when there is no cursor. The cursors of the next and previous
+// pages are returned, nil when there is no such page.
+func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, *Cursor, *Cursor, error) {
+	backward := fq.Cursor != nil && fq.Cursor.Before
+
+	// previous pages are read in the reverse order, then flipped back
+	order, after := "ASC", ">"
+	if (fq.Sort == "desc") != backward {
+		order, after = "DESC", "<"
+	}
+
 	query := `
+		WITH ` + feedItems + `
 		SELECT
-			p.id, p.user_id, p.title, p.content, p.created_at, p.tags,
-			u.username,
-			COUNT(c.id) comments_count
+			p.id, p.user_id, p.title, p.content, p.content_format, COALESCE(p.content_html, ''),
+			p.created_at, p.tags, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, p.quoted_post_id, p.in_reply_to_post_id, u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) comments_count,
+			EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $1) bookmarked_by_me,
+			fi.reposted_by, ru.username, fi.activity_at
 		FROM
-			posts p
-			LEFT JOIN comments c ON p.id = c.post_id
+			latest_items fi
+			INNER JOIN posts p ON p.id = fi.post_id
 			LEFT JOIN users u ON u.id = p.user_id
-			INNER JOIN followers f ON p.user_id = f.follower_id OR p.user_id = $1
-		WHERE (f.user_id = $1 OR p.user_id = $1) AND
+			LEFT JOIN users ru ON ru.id = fi.reposted_by
+		WHERE p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$1") + ` AND
+			` + postNotMuted("$1") + ` AND
 			(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-			($5 = '{}' OR p.tags @> $5::TEXT[])
-		GROUP BY
-			p.id, u.id
-		ORDER BY p.created_at ` + fq.Sort +
+			($5 = '{}' OR p.tags @> $5::TEXT[]) AND
+			($8::TIMESTAMPTZ IS NULL OR fi.activity_at >= $8) AND
+			($9::TIMESTAMPTZ IS NULL OR fi.activity_at <= $9) AND
+			($6::TIMESTAMPTZ IS NULL OR (fi.activity_at, p.id) ` + after + ` ($6, $7))
+		ORDER BY fi.activity_at ` + order + `, p.id ` + order +
 		` OFFSET $2
 		LIMIT $3;
 	`
@@ -55,11 +185,24 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var feedPosts []PostWithMetadata
+	var (
+		afterActivityAt *time.Time
+		afterID         int64
+	)
+
+	if fq.Cursor != nil {
+		afterActivityAt = &fq.Cursor.CreatedAt
+		afterID = fq.Cursor.ID
+	}
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Offset, fq.Limit, fq.Search, pq.Array(fq.Tags))
+	feedPosts := []PostWithMetadata{}
+	entries := []TimelineEntry{}
+
+	// fetching one extra row tells whether there is another page
+	rows, err := s.db.QueryContext(
+		ctx, query, userID, fq.Offset, fq.Limit+1, fq.Search, pq.Array(fq.Tags), afterActivityAt, afterID, fq.Since, fq.Until)
 	if err != nil {
-		return feedPosts, err
+		return nil, nil, nil, err
 	}
 
 	defer rows.Close()
@@ -67,53 +210,520 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 	for rows.Next() {
 		post := PostWithMetadata{}
 
+		var (
+			repostedBy         sql.NullInt64
+			repostedByUsername sql.NullString
+			activityAt         time.Time
+		)
+
 		err := rows.Scan(
 			&post.ID,
 			&post.UserID,
 			&post.Title,
 			&post.Content,
+			&post.ContentFormat,
+			&post.ContentHTML,
 			&post.CreatedAt,
 			pq.Array(&post.Tags),
+			&post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy,
+			&post.QuotedPostID,
+			&post.InReplyToPostID,
 			&post.User.Username,
-			&post.CommentsCount)
+			&post.CommentsCount,
+			&post.BookmarkedByMe,
+			&repostedBy,
+			&repostedByUsername,
+			&activityAt)
 
 		if err != nil {
-			return feedPosts, err
+			return nil, nil, nil, err
+		}
+
+		post.User.ID = post.UserID
+
+		if repostedBy.Valid {
+			repostedAt := activityAt.Format(time.RFC3339)
+			post.RepostedBy = &User{ID: repostedBy.Int64, Username: repostedByUsername.String}
+			post.RepostedAt = &repostedAt
 		}
 
 		feedPosts = append(feedPosts, post)
+		entries = append(entries, TimelineEntry{PostID: post.ID, ActivityAt: activityAt})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	hasMore := len(feedPosts) > fq.Limit
+	if hasMore {
+		feedPosts = feedPosts[:fq.Limit]
+		entries = entries[:fq.Limit]
 	}
 
-	return feedPosts, nil
+	if backward {
+		slices.Reverse(feedPosts)
+		slices.Reverse(entries)
+	}
+
+	next, prev := FeedCursors(fq, entries, hasMore)
+
+	posts := make([]*Post, len(feedPosts))
+	for i := range feedPosts {
+		posts[i] = &feedPosts[i].Post
+	}
+
+	if err := s.hydrate(ctx, posts, userID); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return feedPosts, next, prev, nil
 }
 
-func (s *PostStore) Create(ctx context.Context, post *Post) error {
+// FeedCursors returns the cursors of the pages next to a feed page, given
+// whether more posts were found past the page in the direction it was read.
+// An empty page keeps the position of the query's cursor, so that clients
+// can check for new posts later. The cursors are tagged with the sort of fq.
+func FeedCursors(fq PaginatedFeedQuery, page []TimelineEntry, hasMore bool) (next *Cursor, prev *Cursor) {
+	backward := fq.Cursor != nil && fq.Cursor.Before
+	// a page read from a cursor or an offset has posts before it
+	hasBefore := fq.Cursor != nil || fq.Offset > 0
+
+	if len(page) == 0 {
+		if fq.Cursor == nil {
+			return nil, nil
+		}
+
+		prev = &Cursor{Sort: fq.Sort, CreatedAt: fq.Cursor.CreatedAt, ID: fq.Cursor.ID, Before: true}
+		if backward {
+			next = &Cursor{Sort: fq.Sort, CreatedAt: fq.Cursor.CreatedAt, ID: fq.Cursor.ID}
+		}
+
+		return next, prev
+	}
+
+	first, last := 0, len(page)-1
+
+	if backward || hasMore {
+		next = &Cursor{Sort: fq.Sort, CreatedAt: page[last].ActivityAt, ID: page[last].PostID}
+	}
+
+	if (backward && hasMore) || (!backward && hasBefore) {
+		prev = &Cursor{Sort: fq.Sort, CreatedAt: page[first].ActivityAt, ID: page[first].PostID, Before: true}
+	}
+
+	return next, prev
+}
+
+// GetByTag lists the published posts carrying tag that the viewer can see,
+// but the ones of users they muted, newest first, along with the cursor of
+// the next page (nil on the last page).
+func (s *PostStore) GetByTag(ctx context.Context, tag string, viewerID int64, cq PaginatedCursorQuery) ([]PostWithMetadata, *Cursor, error) {
 	query := `
-		INSERT INTO posts (content, title, tags, user_id)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at, version
+		SELECT
+			p.id, p.user_id, p.title, p.content, p.content_format, COALESCE(p.content_html, ''),
+			p.created_at, p.tags, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, p.quoted_post_id, p.in_reply_to_post_id, u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) comments_count,
+			EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $2) bookmarked_by_me
+		FROM posts p
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.tags @> ARRAY[$1]::TEXT[] AND
+			p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$2") + ` AND
+			` + postNotMuted("$2") + ` AND
+			($3::TIMESTAMPTZ IS NULL OR (p.created_at, p.id) < ($3, $4))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $5
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.
-		QueryRowContext(
-			ctx, query, post.Content, post.Title, pq.Array(post.Tags), post.UserID).
-		Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt, &post.Version)
+	var (
+		afterCreatedAt *time.Time
+		afterID        int64
+	)
+
+	if cq.Cursor != nil {
+		afterCreatedAt = &cq.Cursor.CreatedAt
+		afterID = cq.Cursor.ID
+	}
 
+	// fetching one extra row tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, tag, viewerID, afterCreatedAt, afterID, cq.Limit+1)
 	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	tagPosts := []PostWithMetadata{}
+	createdAts := []time.Time{}
+
+	for rows.Next() {
+		var (
+			post      PostWithMetadata
+			createdAt time.Time
+		)
+
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.ContentFormat,
+			&post.ContentHTML,
+			&createdAt,
+			pq.Array(&post.Tags),
+			&post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy,
+			&post.QuotedPostID,
+			&post.InReplyToPostID,
+			&post.User.Username,
+			&post.CommentsCount,
+			&post.BookmarkedByMe)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		post.CreatedAt = createdAt.Format(time.RFC3339)
+		post.User.ID = post.UserID
+
+		tagPosts = append(tagPosts, post)
+		createdAts = append(createdAts, createdAt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if len(tagPosts) > cq.Limit {
+		tagPosts = tagPosts[:cq.Limit]
+		next = &Cursor{CreatedAt: createdAts[cq.Limit-1], ID: tagPosts[cq.Limit-1].ID}
+	}
+
+	posts := make([]*Post, len(tagPosts))
+	for i := range tagPosts {
+		posts[i] = &tagPosts[i].Post
+	}
+
+	if err := s.hydrate(ctx, posts, viewerID); err != nil {
+		return nil, nil, err
+	}
+
+	return tagPosts, next, nil
+}
+
+// hydrate loads the entities embedded in posts: quoted posts, mentions, and
+// the embeds of the posts and quoted posts.
+func (s *PostStore) hydrate(ctx context.Context, posts []*Post, viewerID int64) error {
+	if err := s.attachQuotedPosts(ctx, posts, viewerID); err != nil {
+		return err
+	}
+
+	if err := s.attachMentions(ctx, posts); err != nil {
+		return err
+	}
+
+	withQuoted := slices.Clone(posts)
+	for _, post := range posts {
+		if post.QuotedPost != nil {
+			withQuoted = append(withQuoted, post.QuotedPost)
+		}
+	}
+
+	return s.attachEmbeds(ctx, withQuoted, viewerID)
+}
+
+// attachEmbeds loads the media attachments, link previews and polls of posts,
+// then collapses the sensitive ones according to the viewer's preferences.
+func (s *PostStore) attachEmbeds(ctx context.Context, posts []*Post, viewerID int64) error {
+	if err := s.attachMedia(ctx, posts); err != nil {
 		return err
 	}
 
+	if err := s.attachLinkPreviews(ctx, posts); err != nil {
+		return err
+	}
+
+	if err := s.attachPolls(ctx, posts, viewerID); err != nil {
+		return err
+	}
+
+	return s.collapseSensitive(ctx, posts, viewerID)
+}
+
+// collapseSensitive marks the media of sensitive posts as sensitive, and
+// collapses the posts with a content warning or sensitive media unless the
+// viewer chose to expand them.
+func (s *PostStore) collapseSensitive(ctx context.Context, posts []*Post, viewerID int64) error {
+	sensitive := false
+
+	for _, post := range posts {
+		post.Collapsed = post.ContentWarning != "" || post.Sensitive
+
+		for i := range post.Attachments {
+			post.Attachments[i].Sensitive = post.Attachments[i].Sensitive || post.Sensitive
+			post.Collapsed = post.Collapsed || post.Attachments[i].Sensitive
+		}
+
+		sensitive = sensitive || post.Collapsed
+	}
+
+	if !sensitive {
+		return nil
+	}
+
+	prefs, err := getPreferences(ctx, s.db, viewerID)
+	if err != nil {
+		return err
+	}
+
+	if prefs.ExpandContentWarnings {
+		for _, post := range posts {
+			post.Collapsed = false
+		}
+	}
+
+	return nil
+}
+
+func (s *PostStore) attachPolls(ctx context.Context, posts []*Post, viewerID int64) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	polls, err := getPolls(ctx, s.db, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Poll = polls[post.ID]
+		if post.Poll != nil {
+			post.Poll.hideResults(viewerID, post.UserID)
+		}
+	}
+
+	return nil
+}
+
+func (s *PostStore) attachLinkPreviews(ctx context.Context, posts []*Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	previews, err := getPostsLinkPreviews(ctx, s.db, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.LinkPreviews = previews[post.ID]
+		if post.LinkPreviews == nil {
+			post.LinkPreviews = []LinkPreview{}
+		}
+	}
+
+	return nil
+}
+
+func (s *PostStore) attachMedia(ctx context.Context, posts []*Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	media, err := getPostsMedia(ctx, s.db, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Attachments = media[post.ID]
+		if post.Attachments == nil {
+			post.Attachments = []Media{}
+		}
+	}
+
 	return nil
 }
 
-func (s *PostStore) GetByID(ctx context.Context, postID int64) (Post, error) {
+func (s *PostStore) attachMentions(ctx context.Context, posts []*Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	mentioned, err := getMentionedUsers(ctx, s.db, ids, false)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Mentions = BuildMentions(post.Content, mentioned[post.ID])
+	}
+
+	return nil
+}
+
+// attachQuotedPosts embeds the posts quoted by posts. Quoted posts that were
+// deleted or that the viewer can't see are left out, keeping only their ID.
+func (s *PostStore) attachQuotedPosts(ctx context.Context, posts []*Post, viewerID int64) error {
+	quotedIDs := []int64{}
+	for _, post := range posts {
+		if post.QuotedPostID != nil {
+			quotedIDs = append(quotedIDs, *post.QuotedPostID)
+		}
+	}
+
+	if len(quotedIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT p.id, p.content, p.content_format, COALESCE(p.content_html, ''), p.title, p.tags, p.user_id,
+			p.created_at, p.updated_at, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, u.username
+		FROM posts p
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($1) AND p.deleted_at IS NULL AND
+			` + postVisibleTo("$2") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(quotedIDs), viewerID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	quoted := map[int64]*Post{}
+
+	for rows.Next() {
+		post := &Post{}
+
+		err := rows.Scan(
+			&post.ID, &post.Content, &post.ContentFormat, &post.ContentHTML,
+			&post.Title, pq.Array(&post.Tags),
+			&post.UserID, &post.CreatedAt, &post.UpdatedAt,
+			&post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy, &post.User.Username,
+		)
+		if err != nil {
+			return err
+		}
+
+		post.User.ID = post.UserID
+		quoted[post.ID] = post
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		if post.QuotedPostID != nil {
+			post.QuotedPost = quoted[*post.QuotedPostID]
+		}
+	}
+
+	return nil
+}
+
+// Create inserts the post along with its mentions, links, poll and media.
+func (s *PostStore) Create(ctx context.Context, post *Post) error {
+	query := `
+		INSERT INTO posts (content, content_format, content_html, title, tags, user_id, status, publish_at, visibility, quoted_post_id, in_reply_to_post_id, content_warning, sensitive, comment_policy)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, created_at, updated_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+
+	if post.Visibility == "" {
+		post.Visibility = PostVisibilityPublic
+	}
+
+	if post.ContentFormat == "" {
+		post.ContentFormat = content.FormatPlain
+	}
+
+	if post.CommentPolicy == "" {
+		post.CommentPolicy = CommentPolicyEveryone
+	}
+
+	post.ContentHTML = content.Render(post.Content, post.ContentFormat)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.
+			QueryRowContext(
+				ctx, query, post.Content, post.ContentFormat, post.ContentHTML, post.Title, pq.Array(post.Tags), post.UserID, post.Status, post.PublishAt, post.Visibility, post.QuotedPostID, post.InReplyToPostID,
+				post.ContentWarning, post.Sensitive, post.CommentPolicy).
+			Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt, &post.Version)
+
+		if err != nil {
+			return err
+		}
+
+		if err := setPostMentions(ctx, tx, post.ID, post.Mentions); err != nil {
+			return err
+		}
+
+		if err := setPostLinks(ctx, tx, post.ID, content.FindLinks(post.Content)); err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			if err := createPoll(ctx, tx, post.ID, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		if len(post.Attachments) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(post.Attachments))
+		for i, media := range post.Attachments {
+			ids[i] = media.ID
+		}
+
+		if err := attachMediaToPost(ctx, tx, post.ID, post.UserID, ids); err != nil {
+			return err
+		}
+
+		for i := range post.Attachments {
+			post.Attachments[i].PostID = &post.ID
+		}
+
+		return nil
+	})
+}
+
+// GetByID fetches a post the viewer is allowed to see. Posts hidden from the
+// viewer are reported as ErrNotFound.
+func (s *PostStore) GetByID(ctx context.Context, postID int64, viewerID int64) (Post, error) {
 	query := `
-		SELECT p.id, p.content, p.title, p.tags, p.user_id, p.created_at, p.updated_at, p.version, u.id, u.username
+		SELECT p.id, p.content, p.content_format, COALESCE(p.content_html, ''), p.title, p.tags, p.user_id,
+			p.created_at, p.updated_at, p.version,
+			p.status, p.publish_at, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy,
+			p.quoted_post_id, p.in_reply_to_post_id, u.id, u.username,
+			EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $2) bookmarked_by_me
 		FROM posts p
 		LEFT JOIN users u ON u.id = p.user_id
-		WHERE p.id = $1
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND
+			` + postVisibleTo("$2") + `
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -121,12 +731,15 @@ func (s *PostStore) GetByID(ctx context.Context, postID int64) (Post, error) {
 
 	var post = Post{}
 
-	err := s.db.QueryRowContext(ctx, query, postID).Scan(
-		&post.ID, &post.Content,
+	err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan(
+		&post.ID, &post.Content, &post.ContentFormat, &post.ContentHTML,
 		&post.Title, pq.Array(&post.Tags),
 		&post.UserID, &post.CreatedAt, &post.UpdatedAt,
 		&post.Version,
+		&post.Status, &post.PublishAt, &post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy,
+		&post.QuotedPostID, &post.InReplyToPostID,
 		&post.User.ID, &post.User.Username,
+		&post.BookmarkedByMe,
 	)
 
 	if err != nil {
@@ -138,12 +751,21 @@ func (s *PostStore) GetByID(ctx context.Context, postID int64) (Post, error) {
 		}
 	}
 
+	if err := s.hydrate(ctx, []*Post{&post}, viewerID); err != nil {
+		return post, err
+	}
+
 	return post, nil
 }
 
+// Delete soft-deletes a post. Its pin is kept so that restoring the post
+// brings it back, but pins of deleted posts are not listed and don't count
+// towards the author's pins.
 func (s *PostStore) Delete(ctx context.Context, postID int64) error {
 	query := `
-		DELETE from posts WHERE id = $1
+		UPDATE posts
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -168,6 +790,7 @@ func (s *PostStore) Delete(ctx context.Context, postID int64) error {
 	return nil
 }
 
+// UpdateOne updates the post and replaces its mentions and links.
 func (s *PostStore) UpdateOne(ctx context.Context, postID int64, updatedPost *Post) error {
 	query := `
 		UPDATE posts
@@ -175,18 +798,270 @@ func (s *PostStore) UpdateOne(ctx context.Context, postID int64, updatedPost *Po
 			title = $2,
 			content = $3,
 			tags = $4,
+			visibility = $6,
+			content_format = $7,
+			content_html = $8,
+			content_warning = $9,
+			sensitive = $10,
+			content_warning_forced = $11,
+			comment_policy = $12,
 			version = version + 1
-		WHERE id = $1 AND version = $5
-		RETURNING title, content, tags, user_id, created_at, updated_at, version
+		WHERE id = $1 AND version = $5 AND deleted_at IS NULL
+		RETURNING title, content, tags, visibility, content_format, content_html,
+			content_warning, sensitive, content_warning_forced, comment_policy, user_id, created_at, updated_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if updatedPost.ContentFormat == "" {
+		updatedPost.ContentFormat = content.FormatPlain
+	}
+
+	updatedPost.ContentHTML = content.Render(updatedPost.Content, updatedPost.ContentFormat)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx, query, postID, updatedPost.Title, updatedPost.Content, pq.Array(updatedPost.Tags), updatedPost.Version, updatedPost.Visibility,
+			updatedPost.ContentFormat, updatedPost.ContentHTML,
+			updatedPost.ContentWarning, updatedPost.Sensitive, updatedPost.ContentWarningForced, updatedPost.CommentPolicy).
+			Scan(&updatedPost.Title, &updatedPost.Content, pq.Array(&updatedPost.Tags), &updatedPost.Visibility,
+				&updatedPost.ContentFormat, &updatedPost.ContentHTML,
+				&updatedPost.ContentWarning, &updatedPost.Sensitive, &updatedPost.ContentWarningForced, &updatedPost.CommentPolicy,
+				&updatedPost.UserID, &updatedPost.CreatedAt, &updatedPost.UpdatedAt, &updatedPost.Version)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := setPostMentions(ctx, tx, postID, updatedPost.Mentions); err != nil {
+			return err
+		}
+
+		return setPostLinks(ctx, tx, postID, content.FindLinks(updatedPost.Content))
+	})
+}
+
+// CanComment tells whether the post's comment policy lets the user comment
+// on it. The author's own comments are not restricted by it.
+func (s *PostStore) CanComment(ctx context.Context, post *Post, userID int64) (bool, error) {
+	switch {
+	case post.UserID == userID || post.CommentPolicy == CommentPolicyEveryone:
+		return true, nil
+	case post.CommentPolicy == CommentPolicyClosed:
+		return false, nil
+	}
+
+	query := `
+		SELECT CASE $3
+			WHEN 'followers' THEN EXISTS (
+				SELECT 1 FROM followers f WHERE f.user_id = $1 AND f.follower_id = $4)
+			WHEN 'mentioned' THEN EXISTS (
+				SELECT 1 FROM mentions m WHERE m.post_id = $2 AND m.comment_id IS NULL AND m.user_id = $4)
+			ELSE FALSE
+		END
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var allowed bool
+
+	err := s.db.QueryRowContext(ctx, query, post.UserID, post.ID, post.CommentPolicy, userID).Scan(&allowed)
+
+	return allowed, err
+}
+
+// Restore brings back a soft-deleted post owned by userID, as long as it was
+// deleted after the deletedAfter cutoff.
+func (s *PostStore) Restore(ctx context.Context, postID int64, userID int64, deletedAfter time.Time) error {
+	// the post gets its pin back, unless the user pinned as many other posts
+	// as they can meanwhile
+	query := `
+		WITH restored AS (
+			UPDATE posts
+			SET deleted_at = NULL
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL AND deleted_at > $3
+			RETURNING id, user_id
+		),
+		unpinned AS (
+			DELETE FROM pinned_posts pp
+			USING restored r
+			WHERE pp.post_id = r.id AND (
+				SELECT COUNT(*)
+				FROM pinned_posts op
+				INNER JOIN posts p ON p.id = op.post_id
+				WHERE op.user_id = r.user_id AND op.post_id <> r.id AND p.deleted_at IS NULL
+			) >= $4
+		)
+		SELECT COUNT(*) FROM restored
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var rowsRestored int64
+
+	err := s.db.QueryRowContext(ctx, query, postID, userID, deletedAfter, MaxPinnedPosts).Scan(&rowsRestored)
+	if err != nil {
+		return err
+	}
+
+	if rowsRestored == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDeleted lists the soft-deleted posts still in the trash, by deletion
+// time.
+func (s *PostStore) GetDeleted(ctx context.Context, lq PaginatedListQuery) ([]Post, error) {
+	query := `
+		SELECT p.id, p.content, p.content_format, COALESCE(p.content_html, ''), p.title, p.tags, p.user_id,
+			p.created_at, p.updated_at, p.version, p.deleted_at,
+			p.status, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, u.id, u.username
+		FROM posts p
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NOT NULL
+		ORDER BY p.deleted_at ` + lq.order() + `
+		OFFSET $1
+		LIMIT $2
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx, query, postID, updatedPost.Title, updatedPost.Content, pq.Array(updatedPost.Tags), updatedPost.Version).
-		Scan(&updatedPost.Title, &updatedPost.Content, pq.Array(&updatedPost.Tags),
-			&updatedPost.UserID, &updatedPost.CreatedAt, &updatedPost.UpdatedAt, &updatedPost.Version)
+	posts := []Post{}
+
+	rows, err := s.db.QueryContext(ctx, query, lq.Offset, lq.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+
+		err := rows.Scan(
+			&post.ID, &post.Content, &post.ContentFormat, &post.ContentHTML,
+			&post.Title, pq.Array(&post.Tags),
+			&post.UserID, &post.CreatedAt, &post.UpdatedAt,
+			&post.Version, &post.DeletedAt,
+			&post.Status, &post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy,
+			&post.User.ID, &post.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// PurgeDeleted hard-deletes posts soft-deleted before the cutoff. Their
+// comments go with them through the comments.post_id foreign key.
+func (s *PostStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM posts
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetDrafts lists the user's posts that are not published yet (drafts and
+// scheduled posts).
+func (s *PostStore) GetDrafts(ctx context.Context, userID int64, lq PaginatedListQuery) ([]Post, error) {
+	query := `
+		SELECT p.id, p.content, p.content_format, COALESCE(p.content_html, ''), p.title, p.tags, p.user_id,
+			p.created_at, p.updated_at, p.version, p.status, p.publish_at, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy
+		FROM posts p
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL AND p.status <> 'published'
+		ORDER BY p.created_at ` + lq.order() + `
+		OFFSET $2
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	posts := []Post{}
+
+	rows, err := s.db.QueryContext(ctx, query, userID, lq.Offset, lq.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+
+		err := rows.Scan(
+			&post.ID, &post.Content, &post.ContentFormat, &post.ContentHTML,
+			&post.Title, pq.Array(&post.Tags),
+			&post.UserID, &post.CreatedAt, &post.UpdatedAt,
+			&post.Version, &post.Status, &post.PublishAt, &post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*Post, len(posts))
+	for i := range posts {
+		ptrs[i] = &posts[i]
+	}
+
+	if err := s.attachEmbeds(ctx, ptrs, userID); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// Publish publishes a draft or scheduled post right away. The post's
+// created_at is moved to the publish time so it lands at the top of feeds.
+func (s *PostStore) Publish(ctx context.Context, post *Post) error {
+	query := `
+		UPDATE posts
+		SET status = 'published', publish_at = NULL, created_at = NOW(), version = version + 1
+		WHERE id = $1 AND status <> 'published' AND deleted_at IS NULL
+		RETURNING status, created_at, updated_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, post.ID).
+		Scan(&post.Status, &post.CreatedAt, &post.UpdatedAt, &post.Version)
 
 	if err != nil {
 		switch {
@@ -197,5 +1072,112 @@ func (s *PostStore) UpdateOne(ctx context.Context, postID int64, updatedPost *Po
 		}
 	}
 
+	post.PublishAt = nil
+
 	return nil
 }
+
+// PublishScheduled publishes every scheduled post whose publish_at is due,
+// using publish_at as the post's created_at, and returns the posts published.
+func (s *PostStore) PublishScheduled(ctx context.Context, now time.Time) ([]Post, error) {
+	query := `
+		UPDATE posts
+		SET status = 'published', created_at = publish_at, version = version + 1
+		WHERE status = 'scheduled' AND publish_at <= $1 AND deleted_at IS NULL
+		RETURNING id, user_id, tags, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	published := []Post{}
+
+	for rows.Next() {
+		post := Post{Status: PostStatusPublished}
+
+		if err := rows.Scan(&post.ID, &post.UserID, pq.Array(&post.Tags), &post.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		published = append(published, post)
+	}
+
+	return published, rows.Err()
+}
+
+// RenderMissingHTML renders and stores the HTML of up to limit posts written
+// before content was rendered on write, returning how many it rendered.
+func (s *PostStore) RenderMissingHTML(ctx context.Context, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var rendered int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT id, content, content_format
+			FROM posts
+			WHERE content_html IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		`
+
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		ids := []int64{}
+		htmls := []string{}
+
+		for rows.Next() {
+			var (
+				id           int64
+				text, format string
+			)
+
+			if err := rows.Scan(&id, &text, &format); err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+			htmls = append(htmls, content.Render(text, format))
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		query = `
+			UPDATE posts p
+			SET content_html = r.html
+			FROM unnest($1::BIGINT[], $2::TEXT[]) AS r(id, html)
+			WHERE p.id = r.id
+		`
+
+		result, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(htmls))
+		if err != nil {
+			return err
+		}
+
+		rendered, err = result.RowsAffected()
+
+		return err
+	})
+
+	return rendered, err
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/tiskae/go-social/internal/content"
+)
+
+func TestRenderMissingHTML(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := &PostStore{db}
+
+	rows := sqlmock.NewRows([]string{"id", "content", "content_format"}).
+		AddRow(1, "<b>old</b> https://example.com", content.FormatPlain).
+		AddRow(2, "**new**", content.FormatMarkdown)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, content, content_format\s+FROM posts\s+WHERE content_html IS NULL`).
+		WithArgs(500).
+		WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE posts p\s+SET content_html = r.html`).
+		WithArgs(
+			pq.Array([]int64{1, 2}),
+			pq.Array([]string{
+				content.RenderPlain("<b>old</b> https://example.com"),
+				content.RenderMarkdown("**new**"),
+			})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	rendered, err := s.RenderMissingHTML(context.Background(), 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rendered != 2 {
+		t.Errorf("rendered %d posts, want 2", rendered)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRenderMissingHTMLNothingLeft(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := &PostStore{db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`WHERE content_html IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "content", "content_format"}))
+	mock.ExpectCommit()
+
+	rendered, err := s.RenderMissingHTML(context.Background(), 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rendered != 0 {
+		t.Errorf("rendered %d posts, want 0", rendered)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Preferences are the user's settings for how content is shown to them.
+type Preferences struct {
+	// ExpandContentWarnings shows posts with a content warning or sensitive
+	// media expanded rather than hidden behind a click.
+	ExpandContentWarnings bool `json:"expand_content_warnings"`
+}
+
+func (s *UserStore) GetPreferences(ctx context.Context, userID int64) (Preferences, error) {
+	return getPreferences(ctx, s.db, userID)
+}
+
+func (s *UserStore) UpdatePreferences(ctx context.Context, userID int64, prefs *Preferences) error {
+	query := `
+		UPDATE users
+		SET expand_content_warnings = $2
+		WHERE id = $1
+		RETURNING expand_content_warnings
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, userID, prefs.ExpandContentWarnings).Scan(&prefs.ExpandContentWarnings)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getPreferences returns the user's preferences, or the defaults for users
+// that don't exist.
+func getPreferences(ctx context.Context, db *sql.DB, userID int64) (Preferences, error) {
+	query := `SELECT expand_content_warnings FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var prefs Preferences
+
+	err := db.QueryRowContext(ctx, query, userID).Scan(&prefs.ExpandContentWarnings)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return prefs, err
+	}
+
+	return prefs, nil
+}
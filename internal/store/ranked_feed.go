@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tiskae/go-social/internal/ranking"
+)
+
+// GetRankingCandidates returns the latest entries of the user's home feed
+// with an activity between since and until, at most limit of them, along with
+// the signals the ranked feed scores them from. The search and tags of fq
+// filter them as in GetUserFeed.
+//
+// Activity and engagement are taken as of rankedAt, so that the same ranking
+// comes out for every page of it: later reposts don't move a post, and later
+// comments, reactions, reposts and bookmarks aren't counted. Comments and
+// reactions removed since are still counted, as they are kept with deleted_at
+// set, but undone reposts and bookmarks leave no trace.
+func (s *PostStore) GetRankingCandidates(ctx context.Context, userID int64, fq PaginatedFeedQuery, rankedAt time.Time, since time.Time, until time.Time, limit int) ([]ranking.Candidate, error) {
+	query := `
+		WITH ` + feedItems + `,
+		ranked_items AS (
+			SELECT DISTINCT ON (post_id) post_id, activity_at
+			FROM feed_items
+			WHERE activity_at <= $7
+			ORDER BY post_id, activity_at DESC
+		),
+		interactions AS (
+			SELECT ap.user_id AS author_id
+			FROM comments vc
+			INNER JOIN posts ap ON ap.id = vc.post_id
+			WHERE vc.user_id = $1 AND vc.created_at <= $7
+			UNION ALL
+			SELECT ap.user_id
+			FROM reposts vr
+			INNER JOIN posts ap ON ap.id = vr.post_id
+			WHERE vr.user_id = $1 AND vr.created_at <= $7
+			UNION ALL
+			SELECT ap.user_id
+			FROM bookmarks vb
+			INNER JOIN posts ap ON ap.id = vb.post_id
+			WHERE vb.user_id = $1 AND vb.created_at <= $7
+			UNION ALL
+			SELECT rc.user_id
+			FROM comment_reactions vcr
+			INNER JOIN comments rc ON rc.id = vcr.comment_id
+			WHERE vcr.user_id = $1 AND
+				vcr.created_at <= $7 AND
+				(vcr.deleted_at IS NULL OR vcr.deleted_at > $7)
+		),
+		author_affinity AS (
+			SELECT author_id, COUNT(*) AS interactions
+			FROM interactions
+			GROUP BY author_id
+		)
+		SELECT
+			fi.post_id, fi.activity_at,
+			(SELECT COUNT(*)
+				FROM comments c
+				WHERE c.post_id = p.id AND
+					c.created_at <= $7 AND
+					(c.deleted_at IS NULL OR c.deleted_at > $7)) comments_count,
+			(SELECT COUNT(*)
+				FROM comment_reactions cr
+				INNER JOIN comments c ON c.id = cr.comment_id
+				WHERE c.post_id = p.id AND
+					(c.deleted_at IS NULL OR c.deleted_at > $7) AND
+					cr.created_at <= $7 AND
+					(cr.deleted_at IS NULL OR cr.deleted_at > $7)) reactions_count,
+			(SELECT COUNT(*) FROM reposts r WHERE r.post_id = p.id AND r.created_at <= $7) reposts_count,
+			COALESCE(aa.interactions, 0),
+			CARDINALITY(ARRAY(SELECT UNNEST(p.tags) INTERSECT SELECT tag FROM tag_follows WHERE user_id = $1))
+		FROM ranked_items fi
+		INNER JOIN posts p ON p.id = fi.post_id
+		LEFT JOIN author_affinity aa ON aa.author_id = p.user_id
+		WHERE p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$1") + ` AND
+			` + postNotMuted("$1") + ` AND
+			(p.title ILIKE '%' || $2 || '%' OR p.content ILIKE '%' || $2 || '%') AND
+			($3 = '{}' OR p.tags @> $3::TEXT[]) AND
+			fi.activity_at >= $4 AND
+			fi.activity_at <= $5
+		ORDER BY fi.activity_at DESC, fi.post_id DESC
+		LIMIT $6
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Search, pq.Array(fq.Tags), since, until, limit, rankedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	candidates := []ranking.Candidate{}
+
+	for rows.Next() {
+		var c ranking.Candidate
+
+		err := rows.Scan(&c.PostID, &c.ActivityAt, &c.Comments, &c.Reactions, &c.Reposts, &c.AuthorInteractions, &c.FollowedTags)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
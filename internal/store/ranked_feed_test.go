@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// Every signal of the ranking is read as of the ranking time, bound to $7, so
+// that the pages of a ranking all come from the same scores.
+func TestGetRankingCandidatesAsOfRankedAt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rankedAt := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	since, until := rankedAt.Add(-48*time.Hour), rankedAt
+
+	mock.ExpectQuery(`(?s)`+
+		`ranked_items AS \(.*WHERE activity_at <= \$7.*\).*`+
+		`vc.created_at <= \$7.*vr.created_at <= \$7.*vb.created_at <= \$7.*`+
+		`vcr.created_at <= \$7 AND\s+\(vcr.deleted_at IS NULL OR vcr.deleted_at > \$7\).*`+
+		`c.created_at <= \$7 AND\s+\(c.deleted_at IS NULL OR c.deleted_at > \$7\)\) comments_count.*`+
+		`cr.created_at <= \$7 AND\s+\(cr.deleted_at IS NULL OR cr.deleted_at > \$7\)\) reactions_count.*`+
+		`r.created_at <= \$7\) reposts_count.*`+
+		`FROM ranked_items fi`).
+		WithArgs(1, "", pq.Array([]string{}), since, until, 100, rankedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "activity_at", "comments", "reactions", "reposts", "interactions", "tags"}).
+			AddRow(3, rankedAt.Add(-time.Hour), 2, 5, 1, 4, 0))
+
+	fq := PaginatedFeedQuery{Sort: "ranked", Tags: []string{}}
+
+	candidates, err := (&PostStore{db}).GetRankingCandidates(context.Background(), 1, fq, rankedAt, since, until, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(candidates) != 1 || candidates[0].PostID != 3 || candidates[0].Reactions != 5 {
+		t.Errorf("candidates = %+v, want post 3 with 5 reactions", candidates)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
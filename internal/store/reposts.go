@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type RepostStore struct {
+	db *sql.DB
+}
+
+func (s *RepostStore) Create(ctx context.Context, userID int64, postID int64) error {
+	query := `
+		INSERT INTO reposts (user_id, post_id)
+		VALUES ($1, $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+
+	if err != nil {
+		pqErr, ok := err.(*pq.Error)
+
+		switch {
+		case ok && pqErr.Code == "23505": // conflict error
+			return ErrConflict
+		case ok && pqErr.Code == "23503": // foreign key violation error
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RepostStore) Delete(ctx context.Context, userID int64, postID int64) error {
+	query := `
+		DELETE FROM reposts
+		WHERE user_id = $1 AND post_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rowsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// no repost found, hence nothing got deleted
+	if rowsDeleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
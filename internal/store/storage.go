@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/tiskae/go-social/internal/ranking"
 )
 
 var (
@@ -18,10 +20,27 @@ var (
 type Storage struct {
 	Posts interface {
 		Create(ctx context.Context, post *Post) error
-		GetByID(ctx context.Context, id int64) (Post, error)
+		GetByID(ctx context.Context, id int64, viewerID int64) (Post, error)
 		Delete(ctx context.Context, id int64) error
 		UpdateOne(ctx context.Context, id int64, post *Post) error
-		GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, *Cursor, *Cursor, error)
+		Restore(ctx context.Context, postID int64, userID int64, deletedAfter time.Time) error
+		GetDeleted(ctx context.Context, lq PaginatedListQuery) ([]Post, error)
+		PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
+		GetDrafts(ctx context.Context, userID int64, lq PaginatedListQuery) ([]Post, error)
+		Publish(ctx context.Context, post *Post) error
+		PublishScheduled(ctx context.Context, now time.Time) ([]Post, error)
+		RenderMissingHTML(ctx context.Context, limit int) (int64, error)
+		GetByTag(ctx context.Context, tag string, viewerID int64, cq PaginatedCursorQuery) ([]PostWithMetadata, *Cursor, error)
+		GetThread(ctx context.Context, post Post, viewerID int64, tq ThreadQuery) (*Thread, error)
+		GetPinned(ctx context.Context, userID int64, viewerID int64) ([]PostWithMetadata, error)
+		GetByUser(ctx context.Context, userID int64, viewerID int64, uq UserPostsQuery) ([]PostWithMetadata, *Cursor, error)
+		CanComment(ctx context.Context, post *Post, userID int64) (bool, error)
+		GetFeedPosts(ctx context.Context, userID int64, entries []TimelineEntry) ([]PostWithMetadata, error)
+		GetRankingCandidates(ctx context.Context, userID int64, fq PaginatedFeedQuery, rankedAt time.Time, since time.Time, until time.Time, limit int) ([]ranking.Candidate, error)
+		GetPublic(ctx context.Context, viewerID int64, eq ExploreQuery) ([]PostWithMetadata, *Cursor, error)
+		GetPopular(ctx context.Context, rankedAt time.Time, since time.Time, w ranking.Weights, limit int) ([]int64, error)
+		GetPublicByIDs(ctx context.Context, postIDs []int64, viewerID int64) ([]PostWithMetadata, error)
 	}
 	Users interface {
 		Activate(ctx context.Context, token string) error
@@ -30,10 +49,16 @@ type Storage struct {
 		Create(ctx context.Context, tx *sql.Tx, user *User) error
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 		Delete(ctx context.Context, userID int64) error
+		GetPreferences(ctx context.Context, userID int64) (Preferences, error)
+		UpdatePreferences(ctx context.Context, userID int64, prefs *Preferences) error
 	}
 	Comments interface {
 		Create(ctx context.Context, comment *Comment) error
-		GetByPostID(ctx context.Context, postID int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID int64, cq CommentsQuery) ([]*Comment, *Cursor, error)
+		GetReplies(ctx context.Context, postID int64, commentID int64, cq CommentsQuery) ([]*Comment, *Cursor, error)
+		GetByID(ctx context.Context, postID int64, commentID int64) (*Comment, error)
+		Update(ctx context.Context, comment *Comment) error
+		Delete(ctx context.Context, commentID int64) error
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID int64, userID int64) error
@@ -42,15 +67,90 @@ type Storage struct {
 	Roles interface {
 		GetByName(ctx context.Context, user *User, roleName string) (bool, error)
 	}
+	Mentions interface {
+		GetByUserID(ctx context.Context, userID int64, cq PaginatedCursorQuery) ([]MentionNotification, *Cursor, error)
+	}
+	Reposts interface {
+		Create(ctx context.Context, userID int64, postID int64) error
+		Delete(ctx context.Context, userID int64, postID int64) error
+	}
+	Bookmarks interface {
+		Create(ctx context.Context, bookmark *Bookmark) error
+		Delete(ctx context.Context, userID int64, postID int64) error
+		GetByUserID(ctx context.Context, userID int64, bq PaginatedBookmarksQuery) ([]Bookmark, *Cursor, error)
+		CreateFolder(ctx context.Context, folder *BookmarkFolder) error
+		GetFolders(ctx context.Context, userID int64) ([]BookmarkFolder, error)
+		DeleteFolder(ctx context.Context, userID int64, folderID int64) error
+	}
+	Tags interface {
+		GetTrending(ctx context.Context, since time.Time, halfLife time.Duration, limit int) ([]TrendingTag, error)
+		Follow(ctx context.Context, userID int64, tag string) error
+		Unfollow(ctx context.Context, userID int64, tag string) error
+		GetFollowed(ctx context.Context, userID int64) ([]FollowedTag, error)
+	}
+	Media interface {
+		Create(ctx context.Context, media *Media) error
+		GetUnattached(ctx context.Context, userID int64, ids []int64) ([]Media, error)
+		GetOrphans(ctx context.Context, createdBefore time.Time, limit int) ([]Media, error)
+		DeleteOrphan(ctx context.Context, mediaID int64) error
+	}
+	LinkPreviews interface {
+		IsFetched(ctx context.Context, url string) (bool, error)
+		GetUnfetched(ctx context.Context, limit int) ([]string, error)
+		Save(ctx context.Context, preview *LinkPreview) error
+	}
+	Polls interface {
+		Vote(ctx context.Context, postID int64, userID int64, optionIDs []int64) error
+	}
+	Blocks interface {
+		Block(ctx context.Context, userID int64, blockedID int64) error
+		Unblock(ctx context.Context, userID int64, blockedID int64) error
+	}
+	Mutes interface {
+		Mute(ctx context.Context, userID int64, mutedID int64) error
+		Unmute(ctx context.Context, userID int64, mutedID int64) error
+	}
+	Pins interface {
+		Pin(ctx context.Context, userID int64, postID int64) error
+		Unpin(ctx context.Context, userID int64, postID int64) error
+		Reorder(ctx context.Context, userID int64, postIDs []int64) error
+	}
+	CommentReactions interface {
+		Set(ctx context.Context, commentID int64, userID int64, emoji string) error
+		Delete(ctx context.Context, commentID int64, userID int64) error
+	}
+	Moderation interface {
+		SetContentWarning(ctx context.Context, post *Post, sensitive bool, action *ModerationAction) error
+	}
+	Timelines interface {
+		CountFollowers(ctx context.Context, userID int64) (int, error)
+		GetAudience(ctx context.Context, userID int64, tags []string, withFollowers bool) ([]int64, error)
+		GetRecentByUser(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error)
+		GetFeedEntries(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error)
+		GetCelebrityEntries(ctx context.Context, userID int64, minFollowers int, tr TimelineRange) ([]TimelineEntry, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowersStore{db},
-		Roles:     &RolesStore{db},
+		Posts:            &PostStore{db},
+		Users:            &UserStore{db},
+		Comments:         &CommentStore{db},
+		Followers:        &FollowersStore{db},
+		Roles:            &RolesStore{db},
+		Mentions:         &MentionStore{db},
+		Reposts:          &RepostStore{db},
+		Bookmarks:        &BookmarkStore{db},
+		Tags:             &TagStore{db},
+		Media:            &MediaStore{db},
+		LinkPreviews:     &LinkPreviewStore{db},
+		Polls:            &PollStore{db},
+		Blocks:           &BlockStore{db},
+		Mutes:            &MuteStore{db},
+		Pins:             &PinStore{db},
+		Moderation:       &ModerationStore{db},
+		CommentReactions: &CommentReactionStore{db},
+		Timelines:        &TimelineStore{db},
 	}
 }
 
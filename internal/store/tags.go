@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TrendingTag struct {
+	Tag          string  `json:"tag"`
+	Score        float64 `json:"score"`
+	PostsCount   int     `json:"posts_count"`
+	AuthorsCount int     `json:"authors_count"`
+}
+
+type FollowedTag struct {
+	Tag       string `json:"tag"`
+	CreatedAt string `json:"created_at"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// GetTrending ranks the tags of the public posts published since the given
+// time. Each post counts for 1 when just published and its weight halves
+// every halfLife, so recent activity outweighs older activity in the window.
+func (s *TagStore) GetTrending(ctx context.Context, since time.Time, halfLife time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+		SELECT
+			tag,
+			SUM(POWER(0.5, EXTRACT(EPOCH FROM (NOW() - p.created_at)) / $2)) score,
+			COUNT(*) posts_count,
+			COUNT(DISTINCT p.user_id) authors_count
+		FROM posts p, unnest(p.tags) AS tag
+		WHERE p.created_at >= $1 AND
+			p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			p.visibility = 'public'
+		GROUP BY tag
+		ORDER BY score DESC, tag
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, halfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+
+	for rows.Next() {
+		var tag TrendingTag
+
+		if err := rows.Scan(&tag.Tag, &tag.Score, &tag.PostsCount, &tag.AuthorsCount); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *TagStore) Follow(ctx context.Context, userID int64, tag string) error {
+	query := `
+		INSERT INTO tag_follows (user_id, tag)
+		VALUES ($1, $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, tag)
+
+	if err != nil {
+		pqErr, ok := err.(*pq.Error)
+
+		if ok && pqErr.Code == "23505" { // conflict error
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *TagStore) Unfollow(ctx context.Context, userID int64, tag string) error {
+	query := `
+		DELETE FROM tag_follows
+		WHERE user_id = $1 AND tag = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, tag)
+	if err != nil {
+		return err
+	}
+
+	rowsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// tag wasn't followed, hence nothing got deleted
+	if rowsDeleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TagStore) GetFollowed(ctx context.Context, userID int64) ([]FollowedTag, error) {
+	query := `
+		SELECT tag, created_at
+		FROM tag_follows
+		WHERE user_id = $1
+		ORDER BY tag
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []FollowedTag{}
+
+	for rows.Next() {
+		var tag FollowedTag
+
+		if err := rows.Scan(&tag.Tag, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
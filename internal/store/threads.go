@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// maxThreadAncestors bounds how far up a thread is walked.
+const maxThreadAncestors = 50
+
+// Thread is the conversation around a post: the posts it replies to, root
+// first, and a tree of the replies to it.
+type Thread struct {
+	Ancestors []Post         `json:"ancestors"`
+	Post      Post           `json:"post"`
+	Replies   []*ThreadReply `json:"replies"`
+}
+
+type ThreadReply struct {
+	Post
+	// Depth is 1 for direct replies to the thread's post.
+	Depth   int            `json:"depth"`
+	Replies []*ThreadReply `json:"replies"`
+}
+
+// threadPostColumns are the columns scanned by scanThreadPost, for posts
+// aliased as p joined with their author aliased as u.
+const threadPostColumns = `
+	p.id, p.user_id, p.title, p.content, p.content_format, COALESCE(p.content_html, ''),
+	p.created_at, p.updated_at, p.tags, p.status, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, p.quoted_post_id, p.in_reply_to_post_id,
+	u.username`
+
+func scanThreadPost(rows *sql.Rows, post *Post, extra ...any) error {
+	dest := []any{
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ContentFormat, &post.ContentHTML,
+		&post.CreatedAt, &post.UpdatedAt, pq.Array(&post.Tags), &post.Status, &post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy,
+		&post.QuotedPostID, &post.InReplyToPostID,
+		&post.User.Username,
+	}
+
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	post.User.ID = post.UserID
+
+	return nil
+}
+
+// GetThread builds the thread around post, as fetched for the viewer by
+// GetByID. Replies are walked breadth first down to tq.Depth levels and at
+// most tq.Limit of them are returned, oldest first at each level. Ancestors
+// and replies that are deleted or hidden from the viewer, by visibility or by
+// a block between the viewer and their author, are left out, along with the
+// replies below them.
+func (s *PostStore) GetThread(ctx context.Context, post Post, viewerID int64, tq ThreadQuery) (*Thread, error) {
+	thread := &Thread{Post: post, Ancestors: []Post{}, Replies: []*ThreadReply{}}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var err error
+
+	if post.InReplyToPostID != nil {
+		thread.Ancestors, err = s.getAncestors(ctx, *post.InReplyToPostID, viewerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replies, err := s.getReplies(ctx, post.ID, viewerID, tq)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, 0, len(thread.Ancestors)+len(replies))
+	for i := range thread.Ancestors {
+		posts = append(posts, &thread.Ancestors[i])
+	}
+
+	// replies come ordered by depth, so parents are always seen first
+	nodes := map[int64]*ThreadReply{}
+
+	for _, reply := range replies {
+		posts = append(posts, &reply.Post)
+		nodes[reply.ID] = reply
+
+		if reply.Depth == 1 {
+			thread.Replies = append(thread.Replies, reply)
+		} else if parent, ok := nodes[*reply.InReplyToPostID]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+
+	if err := s.hydrate(ctx, posts, viewerID); err != nil {
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+// getAncestors walks up a thread from the post with the given ID, returning
+// the posts the viewer can see, root first.
+func (s *PostStore) getAncestors(ctx context.Context, parentID int64, viewerID int64) ([]Post, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, in_reply_to_post_id, 1 AS depth
+			FROM posts
+			WHERE id = $1
+			UNION ALL
+			SELECT p.id, p.in_reply_to_post_id, a.depth + 1
+			FROM posts p
+			INNER JOIN ancestors a ON p.id = a.in_reply_to_post_id
+			WHERE a.depth < $3
+		)
+		SELECT ` + threadPostColumns + `
+		FROM ancestors a
+		INNER JOIN posts p ON p.id = a.id
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NULL AND
+			` + postVisibleTo("$2") + `
+		ORDER BY a.depth DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, parentID, viewerID, maxThreadAncestors)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ancestors := []Post{}
+
+	for rows.Next() {
+		var post Post
+
+		if err := scanThreadPost(rows, &post); err != nil {
+			return nil, err
+		}
+
+		ancestors = append(ancestors, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ancestors, nil
+}
+
+// getReplies walks down a thread from a post, breadth first. Hidden replies
+// stop the walk, so their own replies are never reached.
+func (s *PostStore) getReplies(ctx context.Context, postID int64, viewerID int64, tq ThreadQuery) ([]*ThreadReply, error) {
+	query := `
+		WITH RECURSIVE replies AS (
+			SELECT p.id, 1 AS depth
+			FROM posts p
+			WHERE p.in_reply_to_post_id = $1 AND
+				p.deleted_at IS NULL AND
+				p.status = 'published' AND
+				` + postVisibleTo("$2") + `
+			UNION ALL
+			SELECT p.id, r.depth + 1
+			FROM posts p
+			INNER JOIN replies r ON p.in_reply_to_post_id = r.id
+			WHERE r.depth < $3 AND
+				p.deleted_at IS NULL AND
+				p.status = 'published' AND
+				` + postVisibleTo("$2") + `
+		)
+		SELECT ` + threadPostColumns + `, r.depth
+		FROM replies r
+		INNER JOIN posts p ON p.id = r.id
+		LEFT JOIN users u ON u.id = p.user_id
+		ORDER BY r.depth, p.created_at, p.id
+		LIMIT $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID, tq.Depth, tq.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	replies := []*ThreadReply{}
+
+	for rows.Next() {
+		reply := &ThreadReply{Replies: []*ThreadReply{}}
+
+		if err := scanThreadPost(rows, &reply.Post, &reply.Depth); err != nil {
+			return nil, err
+		}
+
+		replies = append(replies, reply)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return replies, nil
+}
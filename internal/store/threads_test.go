@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var threadPostRowColumns = []string{
+	"id", "user_id", "title", "content", "content_format", "content_html",
+	"created_at", "updated_at", "tags", "status", "visibility", "content_warning", "sensitive", "content_warning_forced", "comment_policy", "quoted_post_id", "in_reply_to_post_id",
+	"username",
+}
+
+func threadPostRow(id int64, inReplyTo any) []driver.Value {
+	now := time.Now()
+
+	return []driver.Value{
+		id, int64(1), "title", "content", "plain", "<p>content</p>",
+		now, now, "{}", "published", "public", "", false, false, "everyone", nil, inReplyTo,
+		"author",
+	}
+}
+
+// The blocks between the viewer and the authors are checked for every post
+// of the thread: all ancestors, and replies at every depth, as a hidden reply
+// also hides the replies below it.
+func TestGetAncestorsLeavesOutBlockedAuthors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`(?s)WITH RECURSIVE ancestors.*WHERE p.deleted_at IS NULL AND.*FROM user_blocks ub.*ub.blocked_id = \$2`).
+		WithArgs(5, 9, maxThreadAncestors).
+		WillReturnRows(sqlmock.NewRows(threadPostRowColumns).
+			AddRow(threadPostRow(1, nil)...).
+			AddRow(threadPostRow(5, int64(1))...))
+
+	ancestors, err := (&PostStore{db}).getAncestors(context.Background(), 5, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ancestors) != 2 || ancestors[0].ID != 1 || ancestors[1].ID != 5 {
+		t.Errorf("ancestors = %+v, want posts 1 then 5", ancestors)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetRepliesLeavesOutBlockedAuthors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	columns := append(threadPostRowColumns, "depth")
+
+	mock.ExpectQuery(`(?s)WITH RECURSIVE replies.*in_reply_to_post_id = \$1.*FROM user_blocks ub.*INNER JOIN replies r.*FROM user_blocks ub.*\)\s+SELECT`).
+		WithArgs(5, 9, 3, 50).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(append(threadPostRow(6, int64(5)), 1)...).
+			AddRow(append(threadPostRow(7, int64(6)), 2)...))
+
+	replies, err := (&PostStore{db}).getReplies(context.Background(), 5, 9, ThreadQuery{Depth: 3, Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replies) != 2 || replies[0].Depth != 1 || replies[1].Depth != 2 {
+		t.Errorf("replies = %+v, want post 6 at depth 1 then 7 at depth 2", replies)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
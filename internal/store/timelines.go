@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TimelineEntry is a post in a home timeline, placed at the time of its most
+// recent activity seen by the timeline's owner: its publication, or a repost.
+type TimelineEntry struct {
+	PostID     int64
+	ActivityAt time.Time
+}
+
+// TimelineRange selects the entries of a timeline past the After cursor, in
+// descending order when Desc is set, with an activity between Since and Until.
+type TimelineRange struct {
+	After *Cursor
+	Desc  bool
+	Since *time.Time
+	Until *time.Time
+	Limit int
+}
+
+// TimelineStore answers the questions home timelines kept outside of the
+// database need: whose timelines an activity goes to, and which entries they
+// start with.
+type TimelineStore struct {
+	db *sql.DB
+}
+
+func (s *TimelineStore) CountFollowers(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM followers WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetAudience returns the users whose home timeline shows a post with the
+// given tags posted or reposted by the user: the user themself, the users
+// following any of the tags and, if withFollowers, the user's followers.
+func (s *TimelineStore) GetAudience(ctx context.Context, userID int64, tags []string, withFollowers bool) ([]int64, error) {
+	query := `
+		SELECT $1::BIGINT
+		UNION
+		SELECT follower_id FROM followers WHERE $3 AND user_id = $1
+		UNION
+		SELECT user_id FROM tag_follows WHERE tag = ANY($2::TEXT[])
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(tags), withFollowers)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	audience := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		audience = append(audience, id)
+	}
+
+	return audience, rows.Err()
+}
+
+// GetRecentByUser lists the latest published posts of the user, newest first,
+// to add to or remove from the timeline of a new or former follower.
+func (s *TimelineStore) GetRecentByUser(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT id, created_at
+		FROM posts
+		WHERE user_id = $1 AND status = 'published' AND deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	return s.queryEntries(ctx, query, userID, limit)
+}
+
+// GetFeedEntries lists the latest entries of the user's home feed, newest
+// first, to build their timeline from.
+func (s *TimelineStore) GetFeedEntries(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		WITH ` + feedItems + `
+		SELECT fi.post_id, fi.activity_at
+		FROM latest_items fi
+		INNER JOIN posts p ON p.id = fi.post_id
+		WHERE p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$1") + ` AND
+			` + postNotMuted("$1") + `
+		ORDER BY fi.activity_at DESC, fi.post_id DESC
+		LIMIT $2
+	`
+
+	return s.queryEntries(ctx, query, userID, limit)
+}
+
+// GetCelebrityEntries lists the entries in range coming from the posts and
+// reposts of the accounts the user follows that have at least minFollowers
+// followers. Those are not fanned out to timelines but read on demand, so a
+// post of theirs doesn't have to be written to every follower's timeline.
+// A post may be listed twice when both posted and reposted by such accounts.
+func (s *TimelineStore) GetCelebrityEntries(ctx context.Context, userID int64, minFollowers int, tr TimelineRange) ([]TimelineEntry, error) {
+	order, after := "ASC", ">"
+	if tr.Desc {
+		order, after = "DESC", "<"
+	}
+
+	inRange := func(activityAt string, postID string) string {
+		return `($3::TIMESTAMPTZ IS NULL OR ` + activityAt + ` >= $3) AND
+			($4::TIMESTAMPTZ IS NULL OR ` + activityAt + ` <= $4) AND
+			($5::TIMESTAMPTZ IS NULL OR (` + activityAt + `, ` + postID + `) ` + after + ` ($5, $6))`
+	}
+
+	query := `
+		WITH celebrities AS (
+			SELECT f.user_id
+			FROM followers f
+			WHERE f.follower_id = $1 AND
+				(SELECT COUNT(*) FROM followers cf WHERE cf.user_id = f.user_id) >= $2
+		),
+		items AS (
+			(SELECT p.id AS post_id, p.created_at AS activity_at
+			FROM posts p
+			WHERE p.user_id IN (SELECT user_id FROM celebrities) AND
+				p.status = 'published' AND
+				p.deleted_at IS NULL AND
+				` + inRange("p.created_at", "p.id") + `
+			ORDER BY p.created_at ` + order + `, p.id ` + order + `
+			LIMIT $7)
+			UNION ALL
+			(SELECT r.post_id, r.created_at
+			FROM reposts r
+			WHERE r.user_id IN (SELECT user_id FROM celebrities) AND
+				` + inRange("r.created_at", "r.post_id") + `
+			ORDER BY r.created_at ` + order + `, r.post_id ` + order + `
+			LIMIT $7)
+		)
+		SELECT post_id, activity_at
+		FROM items
+		ORDER BY activity_at ` + order + `, post_id ` + order + `
+		LIMIT $7
+	`
+
+	var (
+		afterActivityAt *time.Time
+		afterID         int64
+	)
+
+	if tr.After != nil {
+		afterActivityAt = &tr.After.CreatedAt
+		afterID = tr.After.ID
+	}
+
+	return s.queryEntries(ctx, query, userID, minFollowers, tr.Since, tr.Until, afterActivityAt, afterID, tr.Limit)
+}
+
+func (s *TimelineStore) queryEntries(ctx context.Context, query string, args ...any) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+
+	for rows.Next() {
+		var entry TimelineEntry
+
+		if err := rows.Scan(&entry.PostID, &entry.ActivityAt); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetFeedPosts loads the posts of home timeline entries for the user, in the
+// order of the entries. Entries the user may no longer see in their feed are
+// left out: deleted or hidden posts, posts of users they muted, and posts
+// that came from an account or a tag the user stopped following.
+func (s *PostStore) GetFeedPosts(ctx context.Context, userID int64, entries []TimelineEntry) ([]PostWithMetadata, error) {
+	if len(entries) == 0 {
+		return []PostWithMetadata{}, nil
+	}
+
+	query := `
+		SELECT ` + userPostColumns + `,
+			lr.user_id, ru.username
+		FROM UNNEST($1::BIGINT[]) WITH ORDINALITY AS t(post_id, position)
+		INNER JOIN posts p ON p.id = t.post_id
+		LEFT JOIN users u ON u.id = p.user_id
+		LEFT JOIN LATERAL (
+			SELECT r.user_id
+			FROM reposts r
+			WHERE r.post_id = p.id AND
+				(r.user_id = $2 OR r.user_id IN (SELECT user_id FROM followers WHERE follower_id = $2)) AND
+				r.user_id NOT IN (SELECT muted_id FROM user_mutes WHERE user_id = $2)
+			ORDER BY r.created_at DESC
+			LIMIT 1
+		) lr ON TRUE
+		LEFT JOIN users ru ON ru.id = lr.user_id
+		WHERE p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$2") + ` AND
+			` + postNotMuted("$2") + ` AND
+			(p.user_id = $2 OR
+				p.user_id IN (SELECT user_id FROM followers WHERE follower_id = $2) OR
+				lr.user_id IS NOT NULL OR
+				p.tags && ARRAY(SELECT tag FROM tag_follows WHERE user_id = $2))
+		ORDER BY t.position
+	`
+
+	postIDs := make([]int64, len(entries))
+	activityAts := make(map[int64]time.Time, len(entries))
+
+	for i, entry := range entries {
+		postIDs[i] = entry.PostID
+		activityAts[entry.PostID] = entry.ActivityAt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	feedPosts := []PostWithMetadata{}
+
+	for rows.Next() {
+		var (
+			post               PostWithMetadata
+			createdAt          time.Time
+			repostedBy         sql.NullInt64
+			repostedByUsername sql.NullString
+		)
+
+		if err := scanUserPost(rows, &post, &createdAt, &repostedBy, &repostedByUsername); err != nil {
+			return nil, err
+		}
+
+		if repostedBy.Valid {
+			repostedAt := activityAts[post.ID].Format(time.RFC3339)
+			post.RepostedBy = &User{ID: repostedBy.Int64, Username: repostedByUsername.String}
+			post.RepostedAt = &repostedAt
+		}
+
+		feedPosts = append(feedPosts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.hydrate(ctx, postPointers(feedPosts), userID); err != nil {
+		return nil, err
+	}
+
+	return feedPosts, nil
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// userPostColumns are the columns scanned by scanUserPost, for posts aliased
+// as p joined with their author aliased as u. The viewer is bound to $2.
+const userPostColumns = `
+	p.id, p.user_id, p.title, p.content, p.content_format, COALESCE(p.content_html, ''),
+	p.created_at, p.updated_at, p.tags, p.visibility, p.content_warning, p.sensitive, p.content_warning_forced, p.comment_policy, p.quoted_post_id, p.in_reply_to_post_id,
+	u.username,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) comments_count,
+	EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = $2) bookmarked_by_me`
+
+func scanUserPost(rows *sql.Rows, post *PostWithMetadata, createdAt *time.Time, extra ...any) error {
+	dest := []any{
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ContentFormat, &post.ContentHTML,
+		createdAt, &post.UpdatedAt, pq.Array(&post.Tags), &post.Visibility, &post.ContentWarning, &post.Sensitive, &post.ContentWarningForced, &post.CommentPolicy, &post.QuotedPostID, &post.InReplyToPostID,
+		&post.User.Username,
+		&post.CommentsCount,
+		&post.BookmarkedByMe,
+	}
+
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	post.CreatedAt = createdAt.Format(time.RFC3339)
+	post.User.ID = post.UserID
+	post.Status = PostStatusPublished
+
+	return nil
+}
+
+// GetPinned lists the posts pinned by the user that the viewer can see, in
+// the order the user gave them.
+func (s *PostStore) GetPinned(ctx context.Context, userID int64, viewerID int64) ([]PostWithMetadata, error) {
+	query := `
+		SELECT ` + userPostColumns + `
+		FROM pinned_posts pp
+		INNER JOIN posts p ON p.id = pp.post_id
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE pp.user_id = $1 AND
+			p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$2") + `
+		ORDER BY pp.position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	pinned := []PostWithMetadata{}
+
+	for rows.Next() {
+		var (
+			post      PostWithMetadata
+			createdAt time.Time
+		)
+
+		if err := scanUserPost(rows, &post, &createdAt); err != nil {
+			return nil, err
+		}
+
+		post.Pinned = true
+		pinned = append(pinned, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.hydrate(ctx, postPointers(pinned), viewerID); err != nil {
+		return nil, err
+	}
+
+	return pinned, nil
+}
+
+// GetByUser lists the published posts of the user that the viewer can see,
+// along with the posts they reposted if uq.IncludeReposts, newest first by
+// post or repost time. It returns the cursor of the next page too (nil on the
+// last page). Replies are left out unless uq.IncludeReplies, and so are pinned
+// posts when the query isn't filtered, GetPinned listing them.
+func (s *PostStore) GetByUser(ctx context.Context, userID int64, viewerID int64, uq UserPostsQuery) ([]PostWithMetadata, *Cursor, error) {
+	query := `
+		WITH user_items AS (
+			SELECT p.id AS post_id, NULL::BIGINT AS reposted_by, p.created_at AS activity_at
+			FROM posts p
+			WHERE p.user_id = $1
+			UNION ALL
+			SELECT r.post_id, r.user_id, r.created_at
+			FROM reposts r
+			WHERE $7 AND r.user_id = $1
+		),
+		latest_items AS (
+			SELECT DISTINCT ON (post_id) post_id, reposted_by, activity_at
+			FROM user_items
+			ORDER BY post_id, activity_at DESC
+		)
+		SELECT ` + userPostColumns + `,
+			ui.reposted_by, ru.username, ui.activity_at
+		FROM latest_items ui
+		INNER JOIN posts p ON p.id = ui.post_id
+		LEFT JOIN users u ON u.id = p.user_id
+		LEFT JOIN users ru ON ru.id = ui.reposted_by
+		WHERE p.deleted_at IS NULL AND
+			p.status = 'published' AND
+			` + postVisibleTo("$2") + ` AND
+			($6 OR p.in_reply_to_post_id IS NULL) AND
+			($8 OR NOT EXISTS (SELECT 1 FROM pinned_posts pp WHERE pp.user_id = $1 AND pp.post_id = p.id)) AND
+			(p.title ILIKE '%' || $9 || '%' OR p.content ILIKE '%' || $9 || '%') AND
+			($10 = '{}' OR p.tags @> $10::TEXT[]) AND
+			($11::TIMESTAMPTZ IS NULL OR ui.activity_at >= $11) AND
+			($12::TIMESTAMPTZ IS NULL OR ui.activity_at <= $12) AND
+			($3::TIMESTAMPTZ IS NULL OR (ui.activity_at, p.id) < ($3, $4))
+		ORDER BY ui.activity_at DESC, p.id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		afterActivityAt *time.Time
+		afterID         int64
+	)
+
+	if uq.Cursor != nil {
+		afterActivityAt = &uq.Cursor.CreatedAt
+		afterID = uq.Cursor.ID
+	}
+
+	// fetching one extra row tells whether there is a next page
+	rows, err := s.db.QueryContext(
+		ctx, query, userID, viewerID, afterActivityAt, afterID, uq.Limit+1,
+		uq.IncludeReplies, uq.IncludeReposts, uq.Filtered(), uq.Search, pq.Array(uq.Tags), uq.Since, uq.Until,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	userPosts := []PostWithMetadata{}
+	activityAts := []time.Time{}
+
+	for rows.Next() {
+		var (
+			post               PostWithMetadata
+			createdAt          time.Time
+			repostedBy         sql.NullInt64
+			repostedByUsername sql.NullString
+			activityAt         time.Time
+		)
+
+		if err := scanUserPost(rows, &post, &createdAt, &repostedBy, &repostedByUsername, &activityAt); err != nil {
+			return nil, nil, err
+		}
+
+		if repostedBy.Valid {
+			repostedAt := activityAt.Format(time.RFC3339)
+			post.RepostedBy = &User{ID: repostedBy.Int64, Username: repostedByUsername.String}
+			post.RepostedAt = &repostedAt
+		}
+
+		userPosts = append(userPosts, post)
+		activityAts = append(activityAts, activityAt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+
+	if len(userPosts) > uq.Limit {
+		userPosts = userPosts[:uq.Limit]
+		next = &Cursor{CreatedAt: activityAts[uq.Limit-1], ID: userPosts[uq.Limit-1].ID}
+	}
+
+	if err := s.hydrate(ctx, postPointers(userPosts), viewerID); err != nil {
+		return nil, nil, err
+	}
+
+	return userPosts, next, nil
+}
+
+func postPointers(posts []PostWithMetadata) []*Post {
+	pointers := make([]*Post, len(posts))
+	for i := range posts {
+		pointers[i] = &posts[i].Post
+	}
+
+	return pointers
+}
@@ -0,0 +1,78 @@
+package unfurl
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// blockedPrefixes are the special-purpose ranges not covered by the netip
+// helpers used in isPublic.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may embed a private IPv4
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// checkURL only lets through http(s) URLs on the default ports.
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return ErrBlockedAddress
+	}
+
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return ErrBlockedAddress
+	}
+
+	// literal addresses are rejected early, hostnames once resolved
+	if ip, err := netip.ParseAddr(strings.Trim(u.Hostname(), "[]")); err == nil && !isPublic(ip) {
+		return ErrBlockedAddress
+	}
+
+	return nil
+}
+
+// checkAddress vets the resolved ip:port a connection is about to be made
+// to, which also defeats DNS rebinding.
+func checkAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrBlockedAddress
+	}
+
+	if port != "80" && port != "443" {
+		return ErrBlockedAddress
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublic(ip) {
+		return ErrBlockedAddress
+	}
+
+	return nil
+}
+
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
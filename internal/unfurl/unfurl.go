@@ -0,0 +1,231 @@
+// Package unfurl fetches the Open Graph and Twitter card metadata of web
+// pages to build link previews.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	userAgent    = "GopherSocialBot/1.0 (link previews)"
+	maxRedirects = 5
+
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxSiteNameLength    = 100
+	maxImageURLLength    = 2000
+)
+
+var (
+	ErrBlockedAddress = errors.New("unfurl: address is not publicly routable")
+	ErrNotHTML        = errors.New("unfurl: response is not an HTML page")
+)
+
+// Preview is the metadata of a page.
+type Preview struct {
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Unfurler fetches pages with SSRF protections: only http(s) on the standard
+// ports, no connection to private, loopback or otherwise non-public
+// addresses (checked after DNS resolution and on every redirect), and bounded
+// time and response size.
+type Unfurler struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func New(timeout time.Duration, maxBytes int64) *Unfurler {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+
+	transport := &http.Transport{
+		// connecting through a proxy would bypass the address checks
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Minute,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("unfurl: stopped after %d redirects", maxRedirects)
+			}
+			return checkURL(req.URL)
+		},
+	}
+
+	return &Unfurler{client, maxBytes}
+}
+
+// Fetch downloads the page at rawURL and extracts its preview.
+func (u *Unfurler) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unfurl: unexpected status %s", res.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	preview := parse(io.LimitReader(res.Body, u.maxBytes))
+
+	// relative image URLs resolve against the final URL, after redirects
+	if preview.ImageURL != "" {
+		preview.ImageURL = resolveImageURL(res.Request.URL, preview.ImageURL)
+	}
+
+	return preview, nil
+}
+
+// parse reads the metadata out of the head of an HTML page. Open Graph tags
+// win over Twitter card tags, which win over <title> and the description
+// meta tag.
+func parse(r io.Reader) *Preview {
+	var (
+		tokenizer = html.NewTokenizer(r)
+		og        = map[string]string{}
+		twitter   = map[string]string{}
+		title     string
+		desc      string
+		inTitle   bool
+	)
+
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+
+		if tt == html.EndTagToken && token.Data == "head" || tt == html.StartTagToken && token.Data == "body" {
+			break
+		}
+
+		switch {
+		case tt == html.StartTagToken && token.Data == "title":
+			inTitle = true
+		case tt == html.EndTagToken && token.Data == "title":
+			inTitle = false
+		case tt == html.TextToken && inTitle && title == "":
+			title = token.Data
+		case (tt == html.StartTagToken || tt == html.SelfClosingTagToken) && token.Data == "meta":
+			var key, value string
+
+			for _, attr := range token.Attr {
+				switch attr.Key {
+				case "property", "name":
+					if key == "" {
+						key = strings.ToLower(strings.TrimSpace(attr.Val))
+					}
+				case "content":
+					value = attr.Val
+				}
+			}
+
+			switch {
+			case strings.HasPrefix(key, "og:"):
+				setOnce(og, strings.TrimPrefix(key, "og:"), value)
+			case strings.HasPrefix(key, "twitter:"):
+				setOnce(twitter, strings.TrimPrefix(key, "twitter:"), value)
+			case key == "description" && desc == "":
+				desc = value
+			}
+		}
+	}
+
+	return &Preview{
+		Title:       clean(firstNonEmpty(og["title"], twitter["title"], title), maxTitleLength),
+		Description: clean(firstNonEmpty(og["description"], twitter["description"], desc), maxDescriptionLength),
+		ImageURL:    clean(firstNonEmpty(og["image"], og["image:url"], twitter["image"], twitter["image:src"]), maxImageURLLength),
+		SiteName:    clean(og["site_name"], maxSiteNameLength),
+	}
+}
+
+func setOnce(values map[string]string, key, value string) {
+	if _, ok := values[key]; !ok && strings.TrimSpace(value) != "" {
+		values[key] = value
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// clean collapses whitespace, drops invalid UTF-8 and truncates s to max
+// code points.
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+
+	if utf8.RuneCountInString(s) > max {
+		s = string([]rune(s)[:max-1]) + "…"
+	}
+
+	return s
+}
+
+func resolveImageURL(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	return u.String()
+}